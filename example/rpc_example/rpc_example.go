@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 
+	"github.com/actgardner/gogen-avro/v7/container"
 	"github.com/kata-ai/messagebus-golang-kafka/example/schemas"
 	"github.com/kata-ai/messagebus-golang-kafka/messagebus"
 )
@@ -10,10 +11,10 @@ import (
 type handler struct{}
 
 func (handler) HandleMessage(context messagebus.MessageContext) {
-	requestValue := context.Incoming.Value
+	requestValue := context.Incoming.Record.(*schemas.JohnySchema)
 	responseValue := &schemas.JohnySchema{
-		Name: requestValue["name"].(string),
-		Age:  int32(requestValue["age"].(float64)) + 10,
+		Name: requestValue.Name,
+		Age:  requestValue.Age + 10,
 	}
 	key, err := messagebus.NewMessageKey("messagebus_test_response")
 	if err != nil {
@@ -23,6 +24,10 @@ func (handler) HandleMessage(context messagebus.MessageContext) {
 	_, _ = context.Reply(responseRecord)
 }
 
+func newJohnySchema() container.AvroRecord {
+	return schemas.NewJohnySchema()
+}
+
 func main() {
 	producerConfig := messagebus.NewProducerConfig(messagebus.WithCompressionType("gzip"))
 	brokers := []string{
@@ -37,6 +42,8 @@ func main() {
 		consumerConfig,
 		nil,
 		messagebus.WithRpcTimeoutMs(60000),
+		messagebus.WithValueType("message-bus-golang", newJohnySchema),
+		messagebus.WithValueType("message-bus-golang-reply", newJohnySchema),
 	)
 	if err != nil {
 		panic(err)
@@ -0,0 +1,108 @@
+// Package blobstore stores the payloads of the claim-check pattern,
+// on a file system or in an S3-compatible object store.
+package blobstore
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrInvalidBlobId is returned for blob IDs that would escape the
+// directory or prefix of the store.
+var ErrInvalidBlobId = errors.New("invalid blob id")
+
+// FileStore stores blobs as files of a directory, such as a volume
+// shared by producers and consumers.
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore creates a store in dir, creating it if needed.
+func NewFileStore(dir string) (*FileStore, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+func (s *FileStore) Put(id string, data []byte) error {
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file first so that readers never see partial blobs
+	tmp, err := os.CreateTemp(s.dir, ".blob-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+func (s *FileStore) Get(id string) ([]byte, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(path)
+}
+
+func (s *FileStore) Delete(id string) error {
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// DeleteOlderThan deletes the blobs stored before the given time,
+// returning how many were deleted.
+func (s *FileStore) DeleteOlderThan(before time.Time) (int, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return 0, err
+	}
+	deleted := 0
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if info.ModTime().Before(before) {
+			err = os.Remove(filepath.Join(s.dir, entry.Name()))
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return deleted, err
+			}
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (s *FileStore) path(id string) (string, error) {
+	if id == "" || id != filepath.Base(id) || id[0] == '.' {
+		return "", fmt.Errorf("%w: %s", ErrInvalidBlobId, id)
+	}
+	return filepath.Join(s.dir, id), nil
+}
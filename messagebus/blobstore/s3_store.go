@@ -0,0 +1,85 @@
+package blobstore
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// S3Store stores blobs as objects of a bucket of Amazon S3 or of an
+// S3-compatible object store such as MinIO. Blobs can also be
+// expired with a lifecycle rule of the bucket instead of
+// DeleteOlderThan.
+type S3Store struct {
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+type S3Option func(s *S3Store)
+
+// NewS3Store creates a store in a bucket, which must exist.
+// Example:
+// 		client, err := minio.New("s3.amazonaws.com", &minio.Options{Creds: credentials.NewEnvAWS(), Secure: true})
+// 		store := blobstore.NewS3Store(client, "messagebus-claims", blobstore.WithPrefix("transcripts/"))
+func NewS3Store(client *minio.Client, bucket string, opts ...S3Option) *S3Store {
+	store := &S3Store{
+		client: client,
+		bucket: bucket,
+	}
+	for _, opt := range opts {
+		opt(store)
+	}
+	return store
+}
+
+// Configure the prefix of the keys blobs are stored under
+func WithPrefix(prefix string) S3Option {
+	return func(s *S3Store) {
+		s.prefix = prefix
+	}
+}
+
+func (s *S3Store) Put(id string, data []byte) error {
+	_, err := s.client.PutObject(context.Background(), s.bucket, s.prefix+id, bytes.NewReader(data), int64(len(data)),
+		minio.PutObjectOptions{ContentType: "application/octet-stream"})
+	return err
+}
+
+func (s *S3Store) Get(id string) ([]byte, error) {
+	object, err := s.client.GetObject(context.Background(), s.bucket, s.prefix+id, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer object.Close()
+	return io.ReadAll(object)
+}
+
+func (s *S3Store) Delete(id string) error {
+	return s.client.RemoveObject(context.Background(), s.bucket, s.prefix+id, minio.RemoveObjectOptions{})
+}
+
+// DeleteOlderThan deletes the blobs stored under the prefix before
+// the given time, returning how many were deleted.
+func (s *S3Store) DeleteOlderThan(before time.Time) (int, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	deleted := 0
+	for object := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: s.prefix, Recursive: true}) {
+		if object.Err != nil {
+			return deleted, object.Err
+		}
+		if !object.LastModified.Before(before) {
+			continue
+		}
+		err := s.client.RemoveObject(ctx, s.bucket, object.Key, minio.RemoveObjectOptions{})
+		if err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
@@ -0,0 +1,260 @@
+package messagebus
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// Headers tying the chunks of a message together.
+const (
+	chunkMessageIdHeader = keyHeaderPrefix + "chunk-message-id"
+	chunkIndexHeader     = keyHeaderPrefix + "chunk-index"
+	chunkCountHeader     = keyHeaderPrefix + "chunk-count"
+)
+
+var errChunkWithoutKey = errors.New("message key with a MessageId required to send chunks")
+
+// chunkAssembler reassembles the chunks of consumed messages, and
+// keeps the committed offset of a partition from passing the first
+// chunk of a message still being reassembled.
+type chunkAssembler struct {
+	timeout time.Duration
+	sets    map[chunkSetKey]*chunkSet
+	lock    sync.Mutex
+}
+
+type chunkSetKey struct {
+	topic     string
+	partition int32
+	messageId string
+}
+
+type chunkSet struct {
+	chunks      []*kafka.Message
+	received    int
+	firstOffset kafka.Offset
+	startedAt   time.Time
+}
+
+func newChunkAssembler(timeout time.Duration) *chunkAssembler {
+	return &chunkAssembler{
+		timeout: timeout,
+		sets:    make(map[chunkSetKey]*chunkSet),
+	}
+}
+
+// splitValue splits a value into chunks of at most chunkSize bytes.
+func splitValue(value []byte, chunkSize int) [][]byte {
+	var chunks [][]byte
+	for len(value) > chunkSize {
+		chunks = append(chunks, value[:chunkSize])
+		value = value[chunkSize:]
+	}
+	return append(chunks, value)
+}
+
+// sendChunks sends the chunks of a serialized record one after the
+// other, on the partition the first one was sent to, returning the
+// offset of the last one.
+func (m MessageBus) sendChunks(topic string, messageId string, record *SerializedProducerRecord) (kafka.Offset, error) {
+	chunks := splitValue(record.Value, m.producerConfig.ChunkSize)
+	partition := kafka.PartitionAny
+	var offset kafka.Offset
+	for index, chunk := range chunks {
+		headers := append([]kafka.Header{}, record.Headers...)
+		headers = append(headers,
+			kafka.Header{Key: chunkMessageIdHeader, Value: []byte(messageId)},
+			kafka.Header{Key: chunkIndexHeader, Value: []byte(strconv.Itoa(index))},
+			kafka.Header{Key: chunkCountHeader, Value: []byte(strconv.Itoa(len(chunks)))},
+		)
+		delivered, err := m.produce(&kafka.Message{
+			TopicPartition: kafka.TopicPartition{
+				Topic:     &topic,
+				Partition: partition,
+			},
+			Value:         chunk,
+			Key:           record.Key,
+			Headers:       headers,
+			Timestamp:     time.Now(),
+			TimestampType: kafka.TimestampCreateTime,
+		})
+		if err != nil {
+			return -1, fmt.Errorf("cannot send chunk %d of %d: %v", index+1, len(chunks), err)
+		}
+		partition = delivered.Partition
+		offset = delivered.Offset
+	}
+	return offset, nil
+}
+
+// add adds a consumed message to its chunk set, returning the
+// reassembled message once its last chunk is added, along with its
+// chunks in order, and nil before. Messages without any chunk header
+// are returned as is, as their only chunk.
+func (a *chunkAssembler) add(message *kafka.Message) (*kafka.Message, []*kafka.Message, error) {
+	var messageId string
+	var index, count int
+	var err error
+	isChunk := false
+	headers := make([]kafka.Header, 0, len(message.Headers))
+	for _, header := range message.Headers {
+		switch header.Key {
+		case chunkMessageIdHeader:
+			messageId = string(header.Value)
+		case chunkIndexHeader:
+			index, err = strconv.Atoi(string(header.Value))
+		case chunkCountHeader:
+			count, err = strconv.Atoi(string(header.Value))
+		default:
+			headers = append(headers, header)
+			continue
+		}
+		isChunk = true
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid chunk header %s: %v", header.Key, err)
+		}
+	}
+	if !isChunk {
+		return message, []*kafka.Message{message}, nil
+	}
+	if messageId == "" {
+		return nil, nil, fmt.Errorf("chunk %d of %d has no message ID", index, count)
+	}
+	if count <= 0 || index < 0 || index >= count {
+		return nil, nil, fmt.Errorf("invalid chunk %d of %d of message %s", index, count, messageId)
+	}
+
+	key := chunkSetKey{
+		topic:     *message.TopicPartition.Topic,
+		partition: message.TopicPartition.Partition,
+		messageId: messageId,
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	set, ok := a.sets[key]
+	if !ok {
+		set = &chunkSet{
+			chunks:      make([]*kafka.Message, count),
+			firstOffset: message.TopicPartition.Offset,
+			startedAt:   time.Now(),
+		}
+		a.sets[key] = set
+	}
+	if len(set.chunks) != count {
+		return nil, nil, fmt.Errorf("chunk count of message %s changed from %d to %d", messageId, len(set.chunks), count)
+	}
+	if message.TopicPartition.Offset < set.firstOffset {
+		set.firstOffset = message.TopicPartition.Offset
+	}
+	if set.chunks[index] == nil {
+		set.received++
+	}
+	set.chunks[index] = message
+	if set.received < count {
+		return nil, nil, nil
+	}
+	delete(a.sets, key)
+
+	var value []byte
+	for _, chunk := range set.chunks {
+		value = append(value, chunk.Value...)
+	}
+	reassembled := *message
+	reassembled.Value = value
+	reassembled.Headers = headers
+	return &reassembled, set.chunks, nil
+}
+
+// expire discards the chunk sets started for longer than the timeout,
+// returning the chunks received of each.
+func (a *chunkAssembler) expire() [][]*kafka.Message {
+	if a.timeout <= 0 {
+		return nil
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	var expired [][]*kafka.Message
+	for key, set := range a.sets {
+		if time.Since(set.startedAt) > a.timeout {
+			_, _ = fmt.Fprintf(os.Stderr, "discarded message %s from offset %v of %s after receiving %d of %d chunks\n",
+				key.messageId, set.firstOffset, key.topic, set.received, len(set.chunks))
+			delete(a.sets, key)
+			var chunks []*kafka.Message
+			for _, chunk := range set.chunks {
+				if chunk != nil {
+					chunks = append(chunks, chunk)
+				}
+			}
+			expired = append(expired, chunks)
+		}
+	}
+	return expired
+}
+
+// discardChunks sends the chunks of discarded messages to the
+// dead-letter topic when one is configured, returning false if the
+// bus disconnected before they could be.
+func (m *MessageBus) discardChunks(expired [][]*kafka.Message) bool {
+	if m.consumerConfig.DeadLetterTopic == "" || m.deliver == nil {
+		return true
+	}
+	for _, chunks := range expired {
+		err := m.deadLetter(chunks, "incomplete chunks")
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+			return false
+		}
+	}
+	return true
+}
+
+// committableOffset returns the offset to commit after a message of
+// a partition, which is the one of the first chunk of the earliest
+// message of the partition still being reassembled, if any is
+// before the next message.
+func (a *chunkAssembler) committableOffset(partition kafka.TopicPartition) kafka.Offset {
+	offset := partition.Offset + 1
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	for key, set := range a.sets {
+		if key.topic == *partition.Topic && key.partition == partition.Partition && set.firstOffset < offset {
+			offset = set.firstOffset
+		}
+	}
+	return offset
+}
+
+// commit commits the offset after a message, without passing the
+// chunks of messages still being reassembled.
+func (m *MessageBus) commit(message *kafka.Message) {
+	partition := message.TopicPartition
+	partition.Offset = m.chunks.committableOffset(partition)
+	_, _ = m.Consumer.CommitOffsets([]kafka.TopicPartition{partition})
+}
+
+// produce sends a message and waits for its delivery.
+func (m MessageBus) produce(message *kafka.Message) (kafka.TopicPartition, error) {
+	return m.deliver(message)
+}
+
+// deliverWith returns a function sending messages with a producer and
+// waiting for their delivery.
+func deliverWith(producer *kafka.Producer) func(message *kafka.Message) (kafka.TopicPartition, error) {
+	return func(message *kafka.Message) (kafka.TopicPartition, error) {
+		deliveryChan := make(chan kafka.Event)
+		defer close(deliveryChan)
+		err := producer.Produce(message, deliveryChan)
+		if err != nil {
+			return kafka.TopicPartition{Offset: -1}, err
+		}
+		e := <-deliveryChan
+		ev := e.(*kafka.Message)
+		return ev.TopicPartition, ev.TopicPartition.Error
+	}
+}
@@ -0,0 +1,210 @@
+package messagebus
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+func TestChunkAssemblerHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers []kafka.Header
+		whole   bool
+		err     bool
+	}{
+		{"not a chunk", []kafka.Header{{Key: "other", Value: []byte("value")}}, true, false},
+		{"chunk without message id", []kafka.Header{
+			{Key: chunkIndexHeader, Value: []byte("0")},
+			{Key: chunkCountHeader, Value: []byte("2")},
+		}, false, true},
+		{"empty message id", []kafka.Header{
+			{Key: chunkMessageIdHeader, Value: []byte("")},
+			{Key: chunkIndexHeader, Value: []byte("0")},
+			{Key: chunkCountHeader, Value: []byte("2")},
+		}, false, true},
+		{"first of two chunks", []kafka.Header{
+			{Key: chunkMessageIdHeader, Value: []byte("message-1")},
+			{Key: chunkIndexHeader, Value: []byte("0")},
+			{Key: chunkCountHeader, Value: []byte("2")},
+		}, false, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			topic := "transcripts"
+			message := &kafka.Message{
+				TopicPartition: kafka.TopicPartition{Topic: &topic},
+				Value:          []byte("value"),
+				Headers:        test.headers,
+			}
+			reassembled, _, err := newChunkAssembler(time.Minute).add(message)
+			if test.err != (err != nil) {
+				t.Fatalf("error is %v, want an error: %v", err, test.err)
+			}
+			if test.whole != (reassembled == message) {
+				t.Errorf("returned %v for %v", reassembled, message)
+			}
+		})
+	}
+}
+
+func newChunk(messageId string, index int, count int, offset kafka.Offset, value string) *kafka.Message {
+	message := newConsumedMessage("transcripts", offset)
+	message.Value = []byte(value)
+	message.Headers = []kafka.Header{
+		{Key: "other", Value: []byte("value")},
+		{Key: chunkMessageIdHeader, Value: []byte(messageId)},
+		{Key: chunkIndexHeader, Value: []byte(strconv.Itoa(index))},
+		{Key: chunkCountHeader, Value: []byte(strconv.Itoa(count))},
+	}
+	return message
+}
+
+func TestChunkAssemblerReassembly(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks []*kafka.Message
+		want   map[string]string
+	}{
+		{"in order", []*kafka.Message{
+			newChunk("message-1", 0, 3, 10, "a "),
+			newChunk("message-1", 1, 3, 11, "long "),
+			newChunk("message-1", 2, 3, 12, "transcript"),
+		}, map[string]string{"message-1": "a long transcript"}},
+		{"out of order", []*kafka.Message{
+			newChunk("message-1", 2, 3, 10, "transcript"),
+			newChunk("message-1", 0, 3, 11, "a "),
+			newChunk("message-1", 1, 3, 12, "long "),
+		}, map[string]string{"message-1": "a long transcript"}},
+		{"interleaved", []*kafka.Message{
+			newChunk("message-1", 0, 2, 10, "first "),
+			newChunk("message-2", 0, 2, 11, "second "),
+			newChunk("message-1", 1, 2, 12, "transcript"),
+			newChunk("message-2", 1, 2, 13, "transcript"),
+		}, map[string]string{"message-1": "first transcript", "message-2": "second transcript"}},
+		{"redelivered chunk", []*kafka.Message{
+			newChunk("message-1", 0, 2, 10, "a "),
+			newChunk("message-1", 0, 2, 10, "a "),
+			newChunk("message-1", 1, 2, 11, "transcript"),
+		}, map[string]string{"message-1": "a transcript"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assembler := newChunkAssembler(time.Minute)
+			got := make(map[string]string)
+			for _, chunk := range test.chunks {
+				reassembled, chunks, err := assembler.add(chunk)
+				if err != nil {
+					t.Fatalf("cannot add chunk: %v", err)
+				}
+				if reassembled == nil {
+					continue
+				}
+				messageId := headerValue(chunk.Headers, chunkMessageIdHeader)
+				got[messageId] = string(reassembled.Value)
+				if headerValue(reassembled.Headers, chunkIndexHeader) != "" || headerValue(reassembled.Headers, "other") != "value" {
+					t.Errorf("reassembled headers are %v", reassembled.Headers)
+				}
+				for index, chunk := range chunks {
+					if headerValue(chunk.Headers, chunkIndexHeader) != strconv.Itoa(index) {
+						t.Errorf("chunk %d is %v", index, chunk)
+					}
+				}
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("reassembled %v, want %v", got, test.want)
+			}
+			if len(assembler.sets) != 0 {
+				t.Errorf("%d chunk sets left", len(assembler.sets))
+			}
+		})
+	}
+}
+
+func TestChunkAssemblerExpiry(t *testing.T) {
+	assembler := newChunkAssembler(10 * time.Millisecond)
+	if _, _, err := assembler.add(newChunk("message-1", 0, 2, 10, "a ")); err != nil {
+		t.Fatalf("cannot add chunk: %v", err)
+	}
+	if expired := assembler.expire(); len(expired) != 0 {
+		t.Errorf("expired %v before the timeout", expired)
+	}
+	time.Sleep(20 * time.Millisecond)
+	expired := assembler.expire()
+	if len(expired) != 1 || len(expired[0]) != 1 || expired[0][0].TopicPartition.Offset != 10 {
+		t.Errorf("expired %v, want the first chunk", expired)
+	}
+	if len(assembler.sets) != 0 {
+		t.Errorf("%d chunk sets left", len(assembler.sets))
+	}
+}
+
+func TestChunkAssemblerCommittableOffset(t *testing.T) {
+	assembler := newChunkAssembler(time.Minute)
+	committable := func(message *kafka.Message) kafka.Offset {
+		return assembler.committableOffset(message.TopicPartition)
+	}
+	whole := newConsumedMessage("transcripts", 9)
+	if offset := committable(whole); offset != 10 {
+		t.Errorf("committable offset is %d, want 10", offset)
+	}
+	for _, chunk := range []*kafka.Message{newChunk("message-1", 0, 2, 10, "a "), newChunk("message-2", 0, 2, 11, "a ")} {
+		if _, _, err := assembler.add(chunk); err != nil {
+			t.Fatalf("cannot add chunk: %v", err)
+		}
+	}
+	other := newConsumedMessage("summaries", 12)
+	if offset := committable(other); offset != 13 {
+		t.Errorf("committable offset of another topic is %d, want 13", offset)
+	}
+	if offset := committable(newConsumedMessage("transcripts", 12)); offset != 10 {
+		t.Errorf("committable offset is %d, want the first chunk at 10", offset)
+	}
+	last := newChunk("message-1", 1, 2, 13, "transcript")
+	if _, _, err := assembler.add(last); err != nil {
+		t.Fatalf("cannot add chunk: %v", err)
+	}
+	if offset := committable(last); offset != 11 {
+		t.Errorf("committable offset is %d, want the first chunk of message-2 at 11", offset)
+	}
+	last = newChunk("message-2", 1, 2, 14, "transcript")
+	if _, _, err := assembler.add(last); err != nil {
+		t.Fatalf("cannot add chunk: %v", err)
+	}
+	if offset := committable(last); offset != 15 {
+		t.Errorf("committable offset is %d, want 15", offset)
+	}
+}
+
+func TestDeadLetterChunks(t *testing.T) {
+	assembler := newChunkAssembler(time.Minute)
+	var reassembled *kafka.Message
+	var chunks []*kafka.Message
+	var err error
+	for index, value := range []string{"a ", "long ", "transcript"} {
+		reassembled, chunks, err = assembler.add(newChunk("message-1", index, 3, kafka.Offset(10+index), value))
+		if err != nil {
+			t.Fatalf("cannot add chunk: %v", err)
+		}
+	}
+	if reassembled == nil {
+		t.Fatal("message is not reassembled")
+	}
+	letters := &deadLetters{}
+	m := newConsumingBus(letters, WithDeadLetterTopic("transcripts-failed"))
+	if err := m.deadLetter(chunks, "failed"); err != nil {
+		t.Fatalf("cannot dead-letter chunks: %v", err)
+	}
+	if len(letters.messages) != 3 {
+		t.Fatalf("dead-lettered %d messages, want the 3 chunks", len(letters.messages))
+	}
+	for index, message := range letters.messages {
+		if string(message.Value) != string(chunks[index].Value) || headerValue(message.Headers, chunkIndexHeader) != strconv.Itoa(index) ||
+			headerValue(message.Headers, "dlq-original-offset") != strconv.Itoa(10+index) {
+			t.Errorf("dead-lettered %v as chunk %d", message, index)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package messagebus
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/actgardner/gogen-avro/v7/container"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/proto"
+)
+
+// claimCheckHeader marks the messages whose value is a claim check,
+// holding the ID of the blob their payload is stored as.
+const claimCheckHeader = keyHeaderPrefix + "claim-check"
+
+// ClaimCheckSerializer stores the values written by another
+// serializer that exceed a size threshold in a blob store, sending
+// a claim check referencing the blob instead. Claim checks are
+// redeemed for the stored value before the other serializer reads
+// it, after checking its digest. Stored blobs are kept for a
+// retention period, after which Cleanup deletes them.
+type ClaimCheckSerializer struct {
+	serializer ISerializer
+	store      IBlobStore
+	threshold  int
+	retention  time.Duration
+}
+
+type ClaimCheckOption func(s *ClaimCheckSerializer)
+
+// claimCheck references the blob a value is stored as.
+type claimCheck struct {
+	BlobId string `json:"blobId"`
+	Size   int    `json:"size"`
+	Sha256 []byte `json:"sha256"`
+}
+
+// NewClaimCheckSerializer stores values of more than threshold bytes
+// in the store. Blobs are kept for 7 days unless configured otherwise.
+func NewClaimCheckSerializer(serializer ISerializer, store IBlobStore, threshold int, opts ...ClaimCheckOption) *ClaimCheckSerializer {
+	claimCheck := &ClaimCheckSerializer{
+		serializer: serializer,
+		store:      store,
+		threshold:  threshold,
+		retention:  7 * 24 * time.Hour,
+	}
+	for _, opt := range opts {
+		opt(claimCheck)
+	}
+	return claimCheck
+}
+
+// Configure how long stored blobs are kept, which must exceed the
+// time consumers may take to read the messages referencing them
+func WithBlobRetention(retention time.Duration) ClaimCheckOption {
+	return func(s *ClaimCheckSerializer) {
+		s.retention = retention
+	}
+}
+
+func (s ClaimCheckSerializer) Serialize(topic string, record *ProducerRecord) (*SerializedProducerRecord, error) {
+	serialized, err := s.serializer.Serialize(topic, record)
+	if err != nil {
+		return nil, err
+	}
+	if len(serialized.Value) <= s.threshold {
+		return serialized, nil
+	}
+	blobId := uuid.New().String()
+	err = s.store.Put(blobId, serialized.Value)
+	if err != nil {
+		return nil, fmt.Errorf("cannot store value of %d bytes: %v", len(serialized.Value), err)
+	}
+	digest := sha256.Sum256(serialized.Value)
+	reference, err := json.Marshal(claimCheck{BlobId: blobId, Size: len(serialized.Value), Sha256: digest[:]})
+	if err != nil {
+		return nil, err
+	}
+	serialized.Value = reference
+	serialized.Headers = append(serialized.Headers, kafka.Header{Key: claimCheckHeader, Value: []byte(blobId)})
+	return serialized, nil
+}
+
+func (s ClaimCheckSerializer) Deserialize(message *kafka.Message) (*ConsumerRecord, error) {
+	isClaimCheck := false
+	for _, header := range message.Headers {
+		if header.Key == claimCheckHeader {
+			isClaimCheck = true
+		}
+	}
+	if !isClaimCheck || message.Value == nil {
+		return s.serializer.Deserialize(message)
+	}
+	var reference claimCheck
+	err := json.Unmarshal(message.Value, &reference)
+	if err != nil {
+		return nil, fmt.Errorf("invalid claim check: %v", err)
+	}
+	value, err := s.store.Get(reference.BlobId)
+	if err != nil {
+		return nil, fmt.Errorf("cannot fetch value of claim check %s: %v", reference.BlobId, err)
+	}
+	digest := sha256.Sum256(value)
+	if len(value) != reference.Size || !bytes.Equal(digest[:], reference.Sha256) {
+		return nil, fmt.Errorf("value of claim check %s does not match its digest", reference.BlobId)
+	}
+	redeemed := *message
+	redeemed.Value = value
+	return s.serializer.Deserialize(&redeemed)
+}
+
+// Cleanup deletes the blobs stored for longer than the retention
+// period, returning how many were deleted.
+func (s ClaimCheckSerializer) Cleanup() (int, error) {
+	return s.store.DeleteOlderThan(time.Now().Add(-s.retention))
+}
+
+// unwrap returns the serializer this one wraps.
+func (s ClaimCheckSerializer) unwrap() ISerializer {
+	return s.serializer
+}
+
+func (s ClaimCheckSerializer) RegisterValueType(topicOrSubject string, newRecord func() container.AvroRecord) {
+	s.serializer.RegisterValueType(topicOrSubject, newRecord)
+}
+
+func (s ClaimCheckSerializer) RegisterJSONValueType(topicOrSubject string, newValue func() interface{}) {
+	s.serializer.RegisterJSONValueType(topicOrSubject, newValue)
+}
+
+func (s ClaimCheckSerializer) RegisterProtobufValueType(topicOrSubject string, newMessage func() proto.Message) {
+	s.serializer.RegisterProtobufValueType(topicOrSubject, newMessage)
+}
+
+func (s ClaimCheckSerializer) RegisterReaderSchema(topic string, schema string) error {
+	return s.serializer.RegisterReaderSchema(topic, schema)
+}
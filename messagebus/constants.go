@@ -2,6 +2,9 @@ package messagebus
 
 type SecurityProtocol string
 type SASLMechanism string
+type ExpiryAction string
+type SignatureAction string
+type SQLDialect string
 
 const VERSION = "1.0.0"
 const (
@@ -11,3 +14,20 @@ const (
 const (
 	SCRAM_SHA_512 SASLMechanism = "SCRAM-SHA-512"
 )
+
+const (
+	EXPIRY_SKIP        ExpiryAction = "skip"
+	EXPIRY_DEAD_LETTER ExpiryAction = "dead-letter"
+	EXPIRY_COUNT       ExpiryAction = "count"
+)
+
+const (
+	SIGNATURE_REJECT      SignatureAction = "reject"
+	SIGNATURE_DEAD_LETTER SignatureAction = "dead-letter"
+)
+
+const (
+	SQLITE   SQLDialect = "sqlite"
+	POSTGRES SQLDialect = "postgres"
+	MYSQL    SQLDialect = "mysql"
+)
@@ -1,10 +1,34 @@
 package messagebus
 
-import "github.com/confluentinc/confluent-kafka-go/kafka"
+import (
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
 
 type ConsumerConfiguration struct {
 	PollIntervalMs int
 	KafkaConfig    *kafka.ConfigMap
+	// ExpiryAction is what happens to expired messages, which
+	// are handled like any other message when empty
+	ExpiryAction ExpiryAction
+	// MaxMessageAge expires messages without an explicit expiry
+	// once they are older than it, when positive
+	MaxMessageAge time.Duration
+	// DeadLetterTopic receives expired messages with the
+	// EXPIRY_DEAD_LETTER action, forged messages with the
+	// SIGNATURE_DEAD_LETTER action, and messages typed handlers
+	// failed on
+	DeadLetterTopic string
+	// SignatureVerifier verifies the signatures of messages,
+	// which are not verified when it is nil
+	SignatureVerifier ISignatureVerifier
+	// SignatureAction is what happens to unsigned and forged messages
+	SignatureAction SignatureAction
+	// ChunkTimeout discards the chunks of a message that is still
+	// incomplete once it has elapsed since its first chunk, when
+	// positive
+	ChunkTimeout time.Duration
 }
 
 type ConsumerOption func(c *ConsumerConfiguration)
@@ -16,6 +40,7 @@ type ConsumerOption func(c *ConsumerConfiguration)
 // 		NewConsumerConfig("group-1", WithPollIntervalMs(150), WithFetchMinBytes(20))
 // Default values:
 // 		pollIntervalMs: 100
+// 		chunkTimeout: 5m
 // 		fetch.min.bytes: 10
 // 		fetch.wait.max.ms: 10
 // 		max.partition.fetch.bytes: 1048576
@@ -26,6 +51,7 @@ type ConsumerOption func(c *ConsumerConfiguration)
 func NewConsumerConfig(groupId string, opts ...ConsumerOption) *ConsumerConfiguration {
 	consumerConfig := &ConsumerConfiguration{
 		PollIntervalMs: 100,
+		ChunkTimeout:   5 * time.Minute,
 		KafkaConfig: &kafka.ConfigMap{
 			"group.id":                  groupId,
 			"fetch.min.bytes":           10,
@@ -112,3 +138,54 @@ func WithConsumerSASLAuth(protocol SecurityProtocol, mechanism SASLMechanism, us
 		_ = p.KafkaConfig.SetKey("sasl.password", password)
 	}
 }
+
+// Configure what happens to expired messages before they reach the
+// handler: EXPIRY_SKIP commits them without handling them,
+// EXPIRY_DEAD_LETTER sends them to a dead-letter topic first and
+// EXPIRY_COUNT handles them anyway, only counting them.
+// Messages expire at the expiry of their message key, set with
+// WithTTL or WithExpiresAt, or otherwise once maxAge has elapsed
+// since the timestamp of their message key, or their Kafka timestamp
+// without one. A zero maxAge only expires messages with an expiry.
+// Example:
+// 		NewConsumerConfig("group-1", WithMessageExpiry(EXPIRY_SKIP, 10*time.Minute))
+func WithMessageExpiry(action ExpiryAction, maxAge time.Duration) ConsumerOption {
+	return func(c *ConsumerConfiguration) {
+		c.ExpiryAction = action
+		c.MaxMessageAge = maxAge
+	}
+}
+
+// Configure the dead-letter topic receiving expired messages with
+// the EXPIRY_DEAD_LETTER action, forged messages with the
+// SIGNATURE_DEAD_LETTER action, and messages typed handlers failed on
+func WithDeadLetterTopic(topic string) ConsumerOption {
+	return func(c *ConsumerConfiguration) {
+		c.DeadLetterTopic = topic
+	}
+}
+
+// Configure messages to be verified against trusted keys before they
+// reach the handler. Unsigned messages, messages signed with unknown
+// keys or with invalid signatures, and messages claiming another
+// origin service than the one their key is bound to are committed
+// without being handled with SIGNATURE_REJECT, and are sent to the
+// dead-letter topic first with SIGNATURE_DEAD_LETTER.
+// Example:
+// 		NewConsumerConfig("group-1", WithSignatureVerification(keys, SIGNATURE_REJECT))
+func WithSignatureVerification(verifier ISignatureVerifier, action SignatureAction) ConsumerOption {
+	return func(c *ConsumerConfiguration) {
+		c.SignatureVerifier = verifier
+		c.SignatureAction = action
+	}
+}
+
+// Configure how long the chunks of a message sent with chunking are
+// kept waiting for the remaining ones. Incomplete messages are
+// discarded after it, no longer holding back the committed offset
+// of their partition
+func WithChunkTimeout(timeout time.Duration) ConsumerOption {
+	return func(c *ConsumerConfiguration) {
+		c.ChunkTimeout = timeout
+	}
+}
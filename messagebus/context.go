@@ -1,6 +1,7 @@
 package messagebus
 
 import (
+	"context"
 	"errors"
 
 	"github.com/confluentinc/confluent-kafka-go/kafka"
@@ -9,16 +10,22 @@ import (
 type MessageContext struct {
 	Incoming *ConsumerRecord
 	Sender   IMessageBus
+	// Context carries the span handling the message when the bus
+	// is traced, or else the trace context the message was sent in
+	Context context.Context
 }
 
 func (m MessageContext) Reply(record *ProducerRecord) (offset kafka.Offset, err error) {
 	if m.Incoming.Key.ReplyTopic == "" {
 		return -1, errors.New("reply topic undefined")
 	}
+	ctx, span := m.startReplySpan(m.Incoming.Key.ReplyTopic)
 	record.Key = m.Incoming.Key
 	id := m.Incoming.Key.CorrelationId
 	record.Key.CorrelationId = id
 	record.Key.ConversationId = id
+	tracePropagator.Inject(ctx, keyCarrier{key: record.Key})
 	offset, err = m.Sender.Send(m.Incoming.Key.ReplyTopic, record)
+	endSpan(span, err)
 	return
 }
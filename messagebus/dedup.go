@@ -0,0 +1,124 @@
+package messagebus
+
+import (
+	"container/list"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// MemoryDedupStore is an IDedupStore keeping the most recently
+// processed message IDs in memory, up to a capacity and within a
+// time window. Duplicates are only detected within the process, so
+// it suits redeliveries within a consumer lifetime.
+type MemoryDedupStore struct {
+	capacity int
+	window   time.Duration
+	entries  map[string]*list.Element
+	order    *list.List
+	lock     sync.Mutex
+}
+
+type dedupEntry struct {
+	key         string
+	processedAt time.Time
+}
+
+// NewMemoryDedupStore creates a store remembering up to capacity
+// message IDs, for the given window. A zero window remembers them
+// until they are evicted.
+// Example:
+// 		NewMemoryDedupStore(100000, time.Hour)
+func NewMemoryDedupStore(capacity int, window time.Duration) *MemoryDedupStore {
+	return &MemoryDedupStore{
+		capacity: capacity,
+		window:   window,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// Seen reports whether the message was processed by the group
+// within the window.
+func (s *MemoryDedupStore) Seen(group string, messageId string) (bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	element, ok := s.entries[dedupKey(group, messageId)]
+	if !ok {
+		return false, nil
+	}
+	if s.expired(element.Value.(*dedupEntry), time.Now()) {
+		s.remove(element)
+		return false, nil
+	}
+	s.order.MoveToFront(element)
+	return true, nil
+}
+
+// MarkProcessed remembers the message as processed by the group,
+// evicting the least recently used and expired message IDs.
+func (s *MemoryDedupStore) MarkProcessed(group string, messageId string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	now := time.Now()
+	key := dedupKey(group, messageId)
+	if element, ok := s.entries[key]; ok {
+		element.Value.(*dedupEntry).processedAt = now
+		s.order.MoveToFront(element)
+	} else {
+		s.entries[key] = s.order.PushFront(&dedupEntry{key: key, processedAt: now})
+	}
+	for s.order.Len() > s.capacity {
+		s.remove(s.order.Back())
+	}
+	for back := s.order.Back(); back != nil && s.expired(back.Value.(*dedupEntry), now); back = s.order.Back() {
+		s.remove(back)
+	}
+	return nil
+}
+
+func (s *MemoryDedupStore) expired(entry *dedupEntry, now time.Time) bool {
+	return s.window > 0 && now.Sub(entry.processedAt) > s.window
+}
+
+func (s *MemoryDedupStore) remove(element *list.Element) {
+	s.order.Remove(element)
+	delete(s.entries, element.Value.(*dedupEntry).key)
+}
+
+func dedupKey(group string, messageId string) string {
+	return group + "\x00" + messageId
+}
+
+// isDuplicate tells whether a consumed message was already processed
+// by the consumer group. Messages without a message ID are never
+// duplicates, and store errors are reported to stderr so that the
+// message is handled rather than lost.
+func (m *MessageBus) isDuplicate(record *ConsumerRecord) bool {
+	if m.dedupStore == nil || record.Key == nil || record.Key.MessageId == "" {
+		return false
+	}
+	seen, err := m.dedupStore.Seen(m.consumerGroup(), record.Key.MessageId)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "cannot check duplicate of message %s: %v\n", record.Key.MessageId, err)
+		return false
+	}
+	return seen
+}
+
+// markProcessed records a handled message for deduplication.
+func (m *MessageBus) markProcessed(record *ConsumerRecord) {
+	if m.dedupStore == nil || record == nil || record.Key == nil || record.Key.MessageId == "" {
+		return
+	}
+	err := m.dedupStore.MarkProcessed(m.consumerGroup(), record.Key.MessageId)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "cannot mark message %s as processed: %v\n", record.Key.MessageId, err)
+	}
+}
+
+func (m *MessageBus) consumerGroup() string {
+	group, _ := m.consumerConfig.KafkaConfig.Get("group.id", "")
+	return fmt.Sprint(group)
+}
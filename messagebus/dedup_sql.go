@@ -0,0 +1,104 @@
+package messagebus
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// SQLDedupStore is an IDedupStore keeping processed message IDs in
+// a SQL table, so that duplicates are detected across restarts and
+// across the consumers of a group. It works with the database/sql
+// drivers of SQLite, PostgreSQL and MySQL.
+type SQLDedupStore struct {
+	db      *sql.DB
+	dialect SQLDialect
+	table   string
+}
+
+type SQLDedupOption func(s *SQLDedupStore)
+
+// NewSQLDedupStore creates a store over db, a database of the given
+// dialect, creating its table when it does not exist yet. The table
+// is named messagebus_processed_messages unless configured otherwise.
+// Example:
+// 		db, err := sql.Open("sqlite3", "dedup.db")
+// 		store, err := NewSQLDedupStore(db, SQLITE)
+func NewSQLDedupStore(db *sql.DB, dialect SQLDialect, opts ...SQLDedupOption) (*SQLDedupStore, error) {
+	store := &SQLDedupStore{
+		db:      db,
+		dialect: dialect,
+		table:   "messagebus_processed_messages",
+	}
+	for _, opt := range opts {
+		opt(store)
+	}
+	switch dialect {
+	case SQLITE, POSTGRES, MYSQL:
+	default:
+		return nil, fmt.Errorf("unknown SQL dialect %s", dialect)
+	}
+	_, err := db.Exec("CREATE TABLE IF NOT EXISTS " + store.table + " (" +
+		"group_id VARCHAR(255) NOT NULL, " +
+		"message_id VARCHAR(255) NOT NULL, " +
+		"processed_at BIGINT NOT NULL, " +
+		"PRIMARY KEY (group_id, message_id))")
+	if err != nil {
+		return nil, fmt.Errorf("cannot create dedup table %s: %v", store.table, err)
+	}
+	return store, nil
+}
+
+// Configure the name of the table processed message IDs are kept in
+func WithDedupTable(table string) SQLDedupOption {
+	return func(s *SQLDedupStore) {
+		s.table = table
+	}
+}
+
+// Seen reports whether the message was processed by the group.
+func (s *SQLDedupStore) Seen(group string, messageId string) (bool, error) {
+	var count int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM "+s.table+
+		" WHERE group_id = "+s.placeholder(1)+" AND message_id = "+s.placeholder(2),
+		group, messageId).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// MarkProcessed records the message as processed by the group.
+// Marking a message twice is not an error.
+func (s *SQLDedupStore) MarkProcessed(group string, messageId string) error {
+	_, err := s.db.Exec("INSERT INTO "+s.table+" (group_id, message_id, processed_at) VALUES ("+
+		s.placeholder(1)+", "+s.placeholder(2)+", "+s.placeholder(3)+")",
+		group, messageId, time.Now().UnixNano()/int64(time.Millisecond))
+	if err != nil {
+		// The insert fails on the primary key of messages already marked
+		if seen, seenErr := s.Seen(group, messageId); seenErr == nil && seen {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// Purge forgets the messages processed before the given time,
+// returning how many were forgotten, to bound the table size.
+func (s *SQLDedupStore) Purge(before time.Time) (int64, error) {
+	result, err := s.db.Exec("DELETE FROM "+s.table+" WHERE processed_at < "+s.placeholder(1),
+		before.UnixNano()/int64(time.Millisecond))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (s *SQLDedupStore) placeholder(n int) string {
+	if s.dialect == POSTGRES {
+		return "$" + strconv.Itoa(n)
+	}
+	return "?"
+}
@@ -0,0 +1,143 @@
+package messagebus
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func newTestSQLDedupStore(t *testing.T) *SQLDedupStore {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("cannot open database: %v", err)
+	}
+	// Every connection to :memory: opens another database
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = db.Close() })
+	store, err := NewSQLDedupStore(db, SQLITE)
+	if err != nil {
+		t.Fatalf("cannot create dedup store: %v", err)
+	}
+	return store
+}
+
+func assertSeen(t *testing.T, store IDedupStore, group string, messageId string, want bool) {
+	t.Helper()
+	seen, err := store.Seen(group, messageId)
+	if err != nil {
+		t.Fatalf("cannot check message %s: %v", messageId, err)
+	}
+	if seen != want {
+		t.Errorf("message %s of %s is seen: %v, want %v", messageId, group, seen, want)
+	}
+}
+
+func markProcessed(t *testing.T, store IDedupStore, group string, messageIds ...string) {
+	t.Helper()
+	for _, messageId := range messageIds {
+		if err := store.MarkProcessed(group, messageId); err != nil {
+			t.Fatalf("cannot mark message %s: %v", messageId, err)
+		}
+	}
+}
+
+func TestDedupStores(t *testing.T) {
+	stores := map[string]func(t *testing.T) IDedupStore{
+		"memory": func(t *testing.T) IDedupStore { return NewMemoryDedupStore(100, time.Hour) },
+		"sql":    func(t *testing.T) IDedupStore { return newTestSQLDedupStore(t) },
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			assertSeen(t, store, "group-1", "message-1", false)
+			markProcessed(t, store, "group-1", "message-1", "message-1")
+			assertSeen(t, store, "group-1", "message-1", true)
+			// Consumer groups deduplicate on their own
+			assertSeen(t, store, "group-2", "message-1", false)
+			assertSeen(t, store, "group-1", "message-2", false)
+		})
+	}
+}
+
+func TestMemoryDedupStoreEviction(t *testing.T) {
+	store := NewMemoryDedupStore(2, 0)
+	markProcessed(t, store, "group-1", "message-1", "message-2")
+	// Seeing message-1 makes message-2 the least recently used
+	assertSeen(t, store, "group-1", "message-1", true)
+	markProcessed(t, store, "group-1", "message-3")
+	assertSeen(t, store, "group-1", "message-1", true)
+	assertSeen(t, store, "group-1", "message-2", false)
+	assertSeen(t, store, "group-1", "message-3", true)
+}
+
+func TestMemoryDedupStoreWindow(t *testing.T) {
+	store := NewMemoryDedupStore(100, 20*time.Millisecond)
+	markProcessed(t, store, "group-1", "message-1")
+	assertSeen(t, store, "group-1", "message-1", true)
+	time.Sleep(30 * time.Millisecond)
+	assertSeen(t, store, "group-1", "message-1", false)
+	if len(store.entries) != 0 || store.order.Len() != 0 {
+		t.Errorf("%d expired message IDs left", len(store.entries))
+	}
+}
+
+func TestSQLDedupStorePurge(t *testing.T) {
+	store := newTestSQLDedupStore(t)
+	markProcessed(t, store, "group-1", "message-1", "message-2")
+	purged, err := store.Purge(time.Now().Add(-time.Hour))
+	if err != nil || purged != 0 {
+		t.Errorf("purged %d messages, %v, want none", purged, err)
+	}
+	purged, err = store.Purge(time.Now().Add(time.Second))
+	if err != nil || purged != 2 {
+		t.Errorf("purged %d messages, %v, want 2", purged, err)
+	}
+	assertSeen(t, store, "group-1", "message-1", false)
+}
+
+func TestSQLDedupStoreDialect(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("cannot open database: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	if _, err := NewSQLDedupStore(db, "oracle"); err == nil {
+		t.Error("created store of an unknown dialect")
+	}
+}
+
+func TestDeduplicationWithoutMessageId(t *testing.T) {
+	store := NewMemoryDedupStore(100, time.Hour)
+	m := &MessageBus{dedupStore: store, consumerConfig: NewConsumerConfig("group-1")}
+	tests := []struct {
+		name   string
+		record *ConsumerRecord
+	}{
+		{"nil key", &ConsumerRecord{}},
+		{"empty message id", &ConsumerRecord{Key: &MessageKey{}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m.markProcessed(test.record)
+			if m.isDuplicate(test.record) {
+				t.Error("message without message ID is a duplicate")
+			}
+			if len(store.entries) != 0 {
+				t.Errorf("marked %d messages", len(store.entries))
+			}
+		})
+	}
+	m.markProcessed(nil)
+
+	record := &ConsumerRecord{Key: &MessageKey{MessageId: "message-1"}}
+	if m.isDuplicate(record) {
+		t.Error("message is a duplicate before being processed")
+	}
+	m.markProcessed(record)
+	if !m.isDuplicate(record) {
+		t.Error("message is not a duplicate once processed")
+	}
+	assertSeen(t, store, "group-1", "message-1", true)
+}
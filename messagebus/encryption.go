@@ -0,0 +1,203 @@
+package messagebus
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/actgardner/gogen-avro/v7/container"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"google.golang.org/protobuf/proto"
+)
+
+// Headers carrying the envelope of encrypted values.
+const (
+	encryptionKeyIdHeader   = keyHeaderPrefix + "encryption-key-id"
+	encryptionDataKeyHeader = keyHeaderPrefix + "encryption-data-key"
+)
+
+// ErrNotEncrypted is returned when consuming a value without
+// encryption headers from an encrypted topic.
+var ErrNotEncrypted = errors.New("value is not encrypted")
+
+// EncryptingSerializer encrypts the values written by another
+// serializer with envelope encryption: every value is encrypted with
+// AES-GCM under a random data key, which is wrapped with the current
+// key of the key provider and sent in headers along with the ID of
+// that key. Values are decrypted before the other serializer reads
+// them, with the key they were wrapped with, so that keys can be
+// rotated while older messages remain readable.
+type EncryptingSerializer struct {
+	serializer     ISerializer
+	keyProvider    IKeyProvider
+	allowPlaintext bool
+}
+
+type EncryptionOption func(s *EncryptingSerializer)
+
+func NewEncryptingSerializer(serializer ISerializer, keyProvider IKeyProvider, opts ...EncryptionOption) *EncryptingSerializer {
+	encrypting := &EncryptingSerializer{
+		serializer:  serializer,
+		keyProvider: keyProvider,
+	}
+	for _, opt := range opts {
+		opt(encrypting)
+	}
+	return encrypting
+}
+
+// Configure whether values without encryption headers are consumed
+// as plaintext, while producers of a topic are moving to encryption.
+// They fail with ErrNotEncrypted otherwise.
+func WithAllowPlaintext(enabled bool) EncryptionOption {
+	return func(s *EncryptingSerializer) {
+		s.allowPlaintext = enabled
+	}
+}
+
+func (s EncryptingSerializer) Serialize(topic string, record *ProducerRecord) (*SerializedProducerRecord, error) {
+	serialized, err := s.serializer.Serialize(topic, record)
+	if err != nil {
+		return nil, err
+	}
+	if serialized.Value == nil {
+		return serialized, nil
+	}
+	keyId, err := s.keyProvider.CurrentKeyId()
+	if err != nil {
+		return nil, err
+	}
+	dataKey := make([]byte, 32)
+	_, err = io.ReadFull(rand.Reader, dataKey)
+	if err != nil {
+		return nil, err
+	}
+	wrappedKey, err := s.keyProvider.WrapKey(keyId, dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("cannot wrap data key with key %s: %v", keyId, err)
+	}
+	serialized.Value, err = sealAESGCM(dataKey, serialized.Value, encryptionAAD(keyId, topic))
+	if err != nil {
+		return nil, err
+	}
+	serialized.Headers = append(serialized.Headers,
+		kafka.Header{Key: encryptionKeyIdHeader, Value: []byte(keyId)},
+		kafka.Header{Key: encryptionDataKeyHeader, Value: wrappedKey},
+	)
+	return serialized, nil
+}
+
+func (s EncryptingSerializer) Deserialize(message *kafka.Message) (*ConsumerRecord, error) {
+	if message.Value == nil {
+		return s.serializer.Deserialize(message)
+	}
+	var keyId string
+	var wrappedKey []byte
+	for _, header := range message.Headers {
+		switch header.Key {
+		case encryptionKeyIdHeader:
+			keyId = string(header.Value)
+		case encryptionDataKeyHeader:
+			wrappedKey = header.Value
+		}
+	}
+	if keyId == "" || wrappedKey == nil {
+		if s.allowPlaintext {
+			return s.serializer.Deserialize(message)
+		}
+		return nil, ErrNotEncrypted
+	}
+	dataKey, err := s.keyProvider.UnwrapKey(keyId, wrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("cannot unwrap data key with key %s: %v", keyId, err)
+	}
+	var topic string
+	if message.TopicPartition.Topic != nil {
+		topic = *message.TopicPartition.Topic
+	}
+	value, err := openAESGCM(dataKey, message.Value, encryptionAAD(keyId, topic))
+	if err != nil {
+		return nil, err
+	}
+	decrypted := *message
+	decrypted.Value = value
+	return s.serializer.Deserialize(&decrypted)
+}
+
+// unwrap returns the serializer this one wraps.
+func (s EncryptingSerializer) unwrap() ISerializer {
+	return s.serializer
+}
+
+func (s EncryptingSerializer) RegisterValueType(topicOrSubject string, newRecord func() container.AvroRecord) {
+	s.serializer.RegisterValueType(topicOrSubject, newRecord)
+}
+
+func (s EncryptingSerializer) RegisterJSONValueType(topicOrSubject string, newValue func() interface{}) {
+	s.serializer.RegisterJSONValueType(topicOrSubject, newValue)
+}
+
+func (s EncryptingSerializer) RegisterProtobufValueType(topicOrSubject string, newMessage func() proto.Message) {
+	s.serializer.RegisterProtobufValueType(topicOrSubject, newMessage)
+}
+
+func (s EncryptingSerializer) RegisterReaderSchema(topic string, schema string) error {
+	return s.serializer.RegisterReaderSchema(topic, schema)
+}
+
+// encryptionAAD returns the additional data authenticated along with
+// an encrypted value: the ID of the key wrapping its data key and its
+// topic, each prefixed with its length. Values moved to another topic,
+// or headers naming another key, then fail to decrypt.
+func encryptionAAD(keyId string, topic string) []byte {
+	var data []byte
+	for _, part := range []string{keyId, topic} {
+		data = binary.BigEndian.AppendUint32(data, uint32(len(part)))
+		data = append(data, part...)
+	}
+	return data
+}
+
+// sealAESGCM encrypts plaintext with AES-GCM, prefixing the
+// ciphertext with its random nonce.
+func sealAESGCM(key []byte, plaintext []byte, additionalData []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = io.ReadFull(rand.Reader, nonce)
+	if err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// openAESGCM decrypts ciphertext sealed with sealAESGCM.
+func openAESGCM(key []byte, ciphertext []byte, additionalData []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, errors.New("encrypted value is too short")
+	}
+	nonce := ciphertext[:gcm.NonceSize()]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext[gcm.NonceSize():], additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decrypt value: %v", err)
+	}
+	return plaintext, nil
+}
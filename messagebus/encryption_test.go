@@ -0,0 +1,62 @@
+package messagebus
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+func TestEncryptionAuthenticatesTopicAndKeyId(t *testing.T) {
+	provider, err := NewStaticKeyProvider("2024-01", map[string][]byte{
+		"2024-01": bytes.Repeat([]byte{1}, 32),
+		"2024-06": bytes.Repeat([]byte{1}, 32),
+	})
+	if err != nil {
+		t.Fatalf("cannot create key provider: %v", err)
+	}
+	serializer := NewEncryptingSerializer(NewJSONSerializer(), provider)
+	key, err := NewMessageKey("chat-service")
+	if err != nil {
+		t.Fatalf("cannot create message key: %v", err)
+	}
+	serialized, err := serializer.Serialize("conversations", NewProducerRecord(key, map[string]interface{}{"text": "hello"}))
+	if err != nil {
+		t.Fatalf("cannot serialize value: %v", err)
+	}
+
+	// The same key under another ID still has to fail
+	renamed := make([]kafka.Header, len(serialized.Headers))
+	copy(renamed, serialized.Headers)
+	for i, header := range renamed {
+		if header.Key == encryptionKeyIdHeader {
+			renamed[i].Value = []byte("2024-06")
+		}
+	}
+	tests := []struct {
+		name    string
+		topic   string
+		headers []kafka.Header
+		valid   bool
+	}{
+		{"same topic", "conversations", serialized.Headers, true},
+		{"another topic", "announcements", serialized.Headers, false},
+		{"another key id", "conversations", renamed, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			topic := test.topic
+			record, err := serializer.Deserialize(&kafka.Message{
+				TopicPartition: kafka.TopicPartition{Topic: &topic},
+				Value:          serialized.Value,
+				Headers:        test.headers,
+			})
+			if test.valid && (err != nil || record.Value["text"] != "hello") {
+				t.Errorf("decrypted %v, %v", record, err)
+			}
+			if !test.valid && err == nil {
+				t.Error("value decrypted")
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package messagebus
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// expiresAt returns when a consumed message expires, in Unix
+// milliseconds, or 0 when it never does.
+func (c *ConsumerConfiguration) expiresAt(record *ConsumerRecord) int64 {
+	if record.Key != nil && record.Key.ExpiresAt != 0 {
+		return record.Key.ExpiresAt
+	}
+	if c.MaxMessageAge <= 0 {
+		return 0
+	}
+	sentAt := record.Timestamp.UnixNano() / int64(time.Millisecond)
+	if record.Key != nil && record.Key.Timestamp != 0 {
+		sentAt = record.Key.Timestamp
+	}
+	return sentAt + c.MaxMessageAge.Milliseconds()
+}
+
+// validateExpiry checks that expired messages can be dead-lettered.
+func (c *ConsumerConfiguration) validateExpiry(producer *kafka.Producer) error {
+	if c.ExpiryAction != EXPIRY_DEAD_LETTER {
+		return nil
+	}
+	if c.DeadLetterTopic == "" {
+		return errors.New("dead-letter topic required to dead-letter expired messages")
+	}
+	if producer == nil {
+		return errors.New("producer configuration required to dead-letter expired messages")
+	}
+	return nil
+}
+
+// handleExpired applies the expiry action of the consumer to expired
+// messages, consumed as the given chunks, returning whether they must
+// still be handled.
+func (m *MessageBus) handleExpired(chunks []*kafka.Message, record *ConsumerRecord) (bool, error) {
+	config := m.consumerConfig
+	if config.ExpiryAction == "" {
+		return true, nil
+	}
+	expiresAt := config.expiresAt(record)
+	if expiresAt == 0 || time.Now().UnixNano()/int64(time.Millisecond) < expiresAt {
+		return true, nil
+	}
+	atomic.AddInt64(&m.expiredCount, 1)
+	switch config.ExpiryAction {
+	case EXPIRY_COUNT:
+		return true, nil
+	case EXPIRY_DEAD_LETTER:
+		return false, m.deadLetter(chunks, "expired")
+	}
+	return false, nil
+}
+
+// Backoff between attempts to dead-letter a message.
+const (
+	deadLetterMinBackoff = 100 * time.Millisecond
+	deadLetterMaxBackoff = 30 * time.Second
+)
+
+// deadLetter sends consumed messages as they are to the dead-letter
+// topic, along with headers telling where they come from and why.
+// Messages reassembled from chunks are dead-lettered as their chunks,
+// as their whole value may exceed the maximum message size.
+// As committing any later message of their partition would pass them,
+// failed sends are retried with an exponential backoff until the bus
+// disconnects, returning an error only then.
+func (m *MessageBus) deadLetter(chunks []*kafka.Message, reason string) error {
+	for _, message := range chunks {
+		err := m.deadLetterMessage(message, reason)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MessageBus) deadLetterMessage(message *kafka.Message, reason string) error {
+	topic := m.consumerConfig.DeadLetterTopic
+	headers := append([]kafka.Header{}, message.Headers...)
+	headers = append(headers,
+		kafka.Header{Key: "dlq-reason", Value: []byte(reason)},
+		kafka.Header{Key: "dlq-original-topic", Value: []byte(*message.TopicPartition.Topic)},
+		kafka.Header{Key: "dlq-original-partition", Value: []byte(strconv.Itoa(int(message.TopicPartition.Partition)))},
+		kafka.Header{Key: "dlq-original-offset", Value: []byte(message.TopicPartition.Offset.String())},
+	)
+	backoff := deadLetterMinBackoff
+	for {
+		_, err := m.produce(&kafka.Message{
+			TopicPartition: kafka.TopicPartition{
+				Topic:     &topic,
+				Partition: kafka.PartitionAny,
+			},
+			Value:         message.Value,
+			Key:           message.Key,
+			Headers:       headers,
+			Timestamp:     message.Timestamp,
+			TimestampType: kafka.TimestampCreateTime,
+		})
+		if err == nil {
+			return nil
+		}
+		_, _ = fmt.Fprintf(os.Stderr, "cannot dead-letter message at offset %v of %s, retrying in %v: %v\n",
+			message.TopicPartition.Offset, *message.TopicPartition.Topic, backoff, err)
+		select {
+		case <-m.closing:
+			return fmt.Errorf("cannot dead-letter message: %v", err)
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > deadLetterMaxBackoff {
+			backoff = deadLetterMaxBackoff
+		}
+	}
+}
+
+// ExpiredCount returns how many expired messages the bus has consumed
+// since it started, whatever the expiry action.
+func (m *MessageBus) ExpiredCount() int64 {
+	return atomic.LoadInt64(&m.expiredCount)
+}
@@ -0,0 +1,139 @@
+package messagebus
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// deadLetters collects the messages a bus dead-letters, failing to
+// deliver the first ones as many times as told to.
+type deadLetters struct {
+	failures int
+	attempts int
+	messages []*kafka.Message
+}
+
+func (d *deadLetters) deliver(message *kafka.Message) (kafka.TopicPartition, error) {
+	d.attempts++
+	if d.attempts <= d.failures {
+		return kafka.TopicPartition{Offset: -1}, errors.New("broker unavailable")
+	}
+	d.messages = append(d.messages, message)
+	return message.TopicPartition, nil
+}
+
+func newConsumingBus(letters *deadLetters, opts ...ConsumerOption) *MessageBus {
+	return &MessageBus{
+		deliver:        letters.deliver,
+		closing:        make(chan struct{}),
+		consumerConfig: NewConsumerConfig("group-1", opts...),
+	}
+}
+
+func newConsumedMessage(topic string, offset kafka.Offset) *kafka.Message {
+	return &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: 1, Offset: offset},
+		Value:          []byte("value"),
+		Timestamp:      time.Now(),
+	}
+}
+
+func TestExpiresAt(t *testing.T) {
+	sentAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	sentAtMs := sentAt.UnixNano() / int64(time.Millisecond)
+	tests := []struct {
+		name   string
+		maxAge time.Duration
+		key    *MessageKey
+		want   int64
+	}{
+		{"explicit expiry", time.Minute, &MessageKey{Timestamp: sentAtMs, ExpiresAt: sentAtMs + 5}, sentAtMs + 5},
+		{"message key timestamp", time.Minute, &MessageKey{Timestamp: sentAtMs - 1000}, sentAtMs - 1000 + 60000},
+		{"kafka timestamp without key timestamp", time.Minute, &MessageKey{}, sentAtMs + 60000},
+		{"kafka timestamp without key", time.Minute, nil, sentAtMs + 60000},
+		{"no maximum age", 0, &MessageKey{Timestamp: sentAtMs}, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := NewConsumerConfig("group-1", WithMessageExpiry(EXPIRY_SKIP, test.maxAge))
+			got := config.expiresAt(&ConsumerRecord{Key: test.key, Timestamp: sentAt})
+			if got != test.want {
+				t.Errorf("expires at %d, want %d", got, test.want)
+			}
+		})
+	}
+}
+
+func TestHandleExpired(t *testing.T) {
+	expired := &ConsumerRecord{Key: &MessageKey{ExpiresAt: 1}}
+	live := &ConsumerRecord{Key: &MessageKey{ExpiresAt: time.Now().Add(time.Hour).UnixNano() / int64(time.Millisecond)}}
+	tests := []struct {
+		name        string
+		action      ExpiryAction
+		record      *ConsumerRecord
+		handle      bool
+		count       int64
+		deadLetters int
+	}{
+		{"skip", EXPIRY_SKIP, expired, false, 1, 0},
+		{"dead-letter", EXPIRY_DEAD_LETTER, expired, false, 1, 1},
+		{"count", EXPIRY_COUNT, expired, true, 1, 0},
+		{"not expired", EXPIRY_DEAD_LETTER, live, true, 0, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			letters := &deadLetters{}
+			m := newConsumingBus(letters, WithMessageExpiry(test.action, time.Minute), WithDeadLetterTopic("notifications-expired"))
+			handle, err := m.handleExpired([]*kafka.Message{newConsumedMessage("notifications", 7)}, test.record)
+			if err != nil {
+				t.Fatalf("cannot handle expired message: %v", err)
+			}
+			if handle != test.handle {
+				t.Errorf("handle is %v, want %v", handle, test.handle)
+			}
+			if m.ExpiredCount() != test.count {
+				t.Errorf("expired count is %d, want %d", m.ExpiredCount(), test.count)
+			}
+			if len(letters.messages) != test.deadLetters {
+				t.Fatalf("dead-lettered %d messages, want %d", len(letters.messages), test.deadLetters)
+			}
+			for _, message := range letters.messages {
+				if *message.TopicPartition.Topic != "notifications-expired" || headerValue(message.Headers, "dlq-reason") != "expired" ||
+					headerValue(message.Headers, "dlq-original-offset") != "7" {
+					t.Errorf("dead-lettered %v", message)
+				}
+			}
+		})
+	}
+}
+
+func TestDeadLetterRetries(t *testing.T) {
+	letters := &deadLetters{failures: 2}
+	m := newConsumingBus(letters, WithDeadLetterTopic("notifications-failed"))
+	err := m.deadLetter([]*kafka.Message{newConsumedMessage("notifications", 7)}, "failed")
+	if err != nil || len(letters.messages) != 1 || letters.attempts != 3 {
+		t.Errorf("dead-lettered %d messages in %d attempts, %v", len(letters.messages), letters.attempts, err)
+	}
+}
+
+func TestDeadLetterUntilDisconnect(t *testing.T) {
+	letters := &deadLetters{failures: 1 << 30}
+	m := newConsumingBus(letters, WithDeadLetterTopic("notifications-failed"))
+	close(m.closing)
+	err := m.deadLetter([]*kafka.Message{newConsumedMessage("notifications", 7)}, "failed")
+	if err == nil || len(letters.messages) != 0 {
+		t.Errorf("dead-lettered %d messages, %v", len(letters.messages), err)
+	}
+}
+
+func headerValue(headers []kafka.Header, key string) string {
+	for _, header := range headers {
+		if header.Key == key {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
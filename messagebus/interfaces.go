@@ -3,7 +3,10 @@ package messagebus
 import (
 	"time"
 
+	"github.com/actgardner/gogen-avro/v7/container"
 	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/kata-ai/messagebus-golang-kafka/messagebus/schemaregistry"
+	"google.golang.org/protobuf/proto"
 )
 
 type IMessageBus interface {
@@ -21,16 +24,53 @@ type Handler interface {
 type ISerializer interface {
 	Serialize(topic string, record *ProducerRecord) (*SerializedProducerRecord, error)
 	Deserialize(message *kafka.Message) (*ConsumerRecord, error)
+	RegisterValueType(topicOrSubject string, newRecord func() container.AvroRecord)
+	RegisterJSONValueType(topicOrSubject string, newValue func() interface{})
+	RegisterProtobufValueType(topicOrSubject string, newMessage func() proto.Message)
+	RegisterReaderSchema(topic string, schema string) error
 }
 
-type ISchemaRegistryClient interface {
-	getSchema(schemaID int) (*Schema, error)
-	getLatestSchema(subject string, isKey bool) (*Schema, error)
-	getSchemaVersions(subject string, isKey bool) ([]int, error)
-	getSchemaByVersion(subject string, version int, isKey bool) (*Schema, error)
-	createSchema(subject string, schema string, schemaType SchemaType, isKey bool) (*Schema, error)
-	setCredentials(username string, password string)
-	setTimeout(timeout time.Duration)
-	isCachingEnabled(value bool)
-	isCodecCreationEnabled(value bool)
+// IDedupStore records the messages processed by consumer groups,
+// so that redelivered messages are skipped.
+type IDedupStore interface {
+	Seen(group string, messageId string) (bool, error)
+	MarkProcessed(group string, messageId string) error
 }
+
+// IKeyProvider wraps the data keys values are encrypted with, such
+// as with a key management service. Key IDs are sent along with
+// encrypted values so that data keys are unwrapped with the key
+// they were wrapped with.
+type IKeyProvider interface {
+	CurrentKeyId() (string, error)
+	WrapKey(keyId string, dataKey []byte) ([]byte, error)
+	UnwrapKey(keyId string, wrappedKey []byte) ([]byte, error)
+}
+
+// ISigner signs the messages sent, identifying the key it signs
+// with so that consumers can verify the signature.
+type ISigner interface {
+	KeyId() string
+	Sign(data []byte) ([]byte, error)
+}
+
+// ISignatureVerifier verifies the signatures of consumed messages,
+// returning the origin service the signing key is bound to. Keys
+// that are not bound to an origin service are rejected.
+type ISignatureVerifier interface {
+	Verify(keyId string, data []byte, signature []byte) (string, error)
+}
+
+// IBlobStore stores the values sent with the claim-check pattern,
+// such as the stores of the blobstore package.
+type IBlobStore interface {
+	Put(id string, data []byte) error
+	Get(id string) ([]byte, error)
+	Delete(id string) error
+	DeleteOlderThan(before time.Time) (int, error)
+}
+
+// ISchemaRegistryClient is the client serializers look schemas up
+// with. Any implementation of schemaregistry.IClient can be given
+// to NewSerializerWithClient.
+type ISchemaRegistryClient = schemaregistry.IClient
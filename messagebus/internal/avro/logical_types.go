@@ -0,0 +1,202 @@
+package avro
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// logicalTypes are the logical types goavro decodes into other native
+// values than their underlying type, by underlying type. Other logical
+// types are read as their underlying type.
+var logicalTypes = map[string]bool{
+	"int.date":              true,
+	"int.time-millis":       true,
+	"long.time-micros":      true,
+	"long.timestamp-millis": true,
+	"long.timestamp-micros": true,
+	"bytes.decimal":         true,
+	"fixed.decimal":         true,
+}
+
+// parseLogicalType records the logical type of a primitive or fixed
+// type when goavro decodes it into other native values.
+func parseLogicalType(t *avroType, v map[string]interface{}) {
+	logicalType, _ := v["logicalType"].(string)
+	if !logicalTypes[t.kind+"."+logicalType] {
+		return
+	}
+	t.logicalType = logicalType
+	scale, _ := v["scale"].(float64)
+	t.scale = int(scale)
+}
+
+// resolveLogical resolves logical types by the types underlying them,
+// as the Avro specification does: values are converted to their
+// underlying type, projected, then converted to the logical type of
+// the reader.
+func resolveLogical(writer *avroType, reader *avroType, project Projection) (Projection, error) {
+	if writer.kind == reader.kind && writer.logicalType == reader.logicalType {
+		if writer.scale != reader.scale {
+			return nil, fmt.Errorf("cannot read decimal of scale %d as decimal of scale %d", writer.scale, reader.scale)
+		}
+		return project, nil
+	}
+	toRaw, fromRaw := toUnderlying(writer), fromUnderlying(reader)
+	return func(v interface{}) (interface{}, error) {
+		raw, err := toRaw(v)
+		if err != nil {
+			return nil, err
+		}
+		value, err := project(raw)
+		if err != nil {
+			return nil, err
+		}
+		return fromRaw(value)
+	}, nil
+}
+
+// toUnderlying converts the native values goavro decodes a logical
+// type into to the native values of its underlying type.
+func toUnderlying(t *avroType) Projection {
+	switch t.logicalType {
+	case "date":
+		return func(v interface{}) (interface{}, error) {
+			date, ok := v.(time.Time)
+			if !ok {
+				return nil, unexpectedNative(v, t)
+			}
+			return int32(date.UnixNano() / int64(24*time.Hour)), nil
+		}
+	case "time-millis":
+		return func(v interface{}) (interface{}, error) {
+			duration, ok := v.(time.Duration)
+			if !ok {
+				return nil, unexpectedNative(v, t)
+			}
+			return int32(duration / time.Millisecond), nil
+		}
+	case "time-micros":
+		return func(v interface{}) (interface{}, error) {
+			duration, ok := v.(time.Duration)
+			if !ok {
+				return nil, unexpectedNative(v, t)
+			}
+			return int64(duration / time.Microsecond), nil
+		}
+	case "timestamp-millis":
+		return func(v interface{}) (interface{}, error) {
+			timestamp, ok := v.(time.Time)
+			if !ok {
+				return nil, unexpectedNative(v, t)
+			}
+			return timestamp.UnixNano() / int64(time.Millisecond), nil
+		}
+	case "timestamp-micros":
+		return func(v interface{}) (interface{}, error) {
+			timestamp, ok := v.(time.Time)
+			if !ok {
+				return nil, unexpectedNative(v, t)
+			}
+			return timestamp.Unix()*1e6 + int64(timestamp.Nanosecond()/1e3), nil
+		}
+	case "decimal":
+		return func(v interface{}) (interface{}, error) {
+			switch decimal := v.(type) {
+			case []byte:
+				// goavro keeps the bytes of decimals exceeding 64 bits
+				return decimal, nil
+			case *big.Rat:
+				unscaled := new(big.Int).Mul(decimal.Num(), pow10(t.scale))
+				return signedBytes(unscaled.Quo(unscaled, decimal.Denom()), t.size)
+			}
+			return nil, unexpectedNative(v, t)
+		}
+	}
+	return identity
+}
+
+// fromUnderlying converts the native values of the type underlying
+// a logical type to the native values goavro decodes it into.
+func fromUnderlying(t *avroType) Projection {
+	switch t.logicalType {
+	case "date":
+		return func(v interface{}) (interface{}, error) {
+			days, ok := v.(int32)
+			if !ok {
+				return nil, unexpectedNative(v, t)
+			}
+			return time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, int(days)), nil
+		}
+	case "time-millis":
+		return func(v interface{}) (interface{}, error) {
+			millis, ok := v.(int32)
+			if !ok {
+				return nil, unexpectedNative(v, t)
+			}
+			return time.Duration(millis) * time.Millisecond, nil
+		}
+	case "time-micros":
+		return func(v interface{}) (interface{}, error) {
+			micros, ok := v.(int64)
+			if !ok {
+				return nil, unexpectedNative(v, t)
+			}
+			return time.Duration(micros) * time.Microsecond, nil
+		}
+	case "timestamp-millis":
+		return func(v interface{}) (interface{}, error) {
+			millis, ok := v.(int64)
+			if !ok {
+				return nil, unexpectedNative(v, t)
+			}
+			return time.Unix(millis/1e3, (millis%1e3)*int64(time.Millisecond)).UTC(), nil
+		}
+	case "timestamp-micros":
+		return func(v interface{}) (interface{}, error) {
+			micros, ok := v.(int64)
+			if !ok {
+				return nil, unexpectedNative(v, t)
+			}
+			return time.Unix(micros/1e6, (micros%1e6)*int64(time.Microsecond)).UTC(), nil
+		}
+	case "decimal":
+		return func(v interface{}) (interface{}, error) {
+			data, ok := v.([]byte)
+			if !ok {
+				return nil, unexpectedNative(v, t)
+			}
+			unscaled := new(big.Int).SetBytes(data)
+			if len(data) > 0 && data[0]&0x80 != 0 {
+				unscaled.Sub(unscaled, new(big.Int).Lsh(big.NewInt(1), uint(len(data))*8))
+			}
+			if unscaled.BitLen() > 64 {
+				// goavro keeps the bytes of decimals exceeding 64 bits
+				return data, nil
+			}
+			return new(big.Rat).SetFrac(unscaled, pow10(t.scale)), nil
+		}
+	}
+	return identity
+}
+
+// signedBytes returns the big-endian two's complement form of n,
+// sign-extended to size bytes for fixed types.
+func signedBytes(n *big.Int, size int) ([]byte, error) {
+	length := n.BitLen()/8 + 1
+	if size > 0 {
+		if length > size {
+			return nil, fmt.Errorf("decimal %v does not fit in %d bytes", n, size)
+		}
+		length = size
+	}
+	value := n
+	if n.Sign() < 0 {
+		value = new(big.Int).Add(n, new(big.Int).Lsh(big.NewInt(1), uint(length)*8))
+	}
+	return value.FillBytes(make([]byte, length)), nil
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
@@ -0,0 +1,687 @@
+// Package avro resolves data written with one Avro schema into
+// another one, for the serializer and for schema compatibility checks.
+package avro
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// avroType is a parsed Avro schema, detailed enough to
+// resolve data written with one schema into another one
+// following the rules of the Avro specification.
+type avroType struct {
+	kind        string
+	name        string
+	aliases     []string
+	fields      []avroField
+	symbols     []string
+	enumDefault *string
+	items       *avroType
+	values      *avroType
+	size        int
+	branches    []*avroType
+	// logicalType is set for the logical types goavro decodes into
+	// other native values than those of their underlying type
+	logicalType string
+	scale       int
+}
+
+type avroField struct {
+	name         string
+	aliases      []string
+	typ          *avroType
+	defaultValue interface{}
+	hasDefault   bool
+}
+
+// Projection converts a goavro native value written with
+// a writer schema into a native value of a reader schema.
+type Projection func(native interface{}) (interface{}, error)
+
+var primitiveTypes = map[string]bool{
+	"null": true, "boolean": true, "int": true, "long": true,
+	"float": true, "double": true, "bytes": true, "string": true,
+}
+
+// parseAvroSchema parses a schema in its JSON form.
+func parseAvroSchema(schema string) (*avroType, error) {
+	var raw interface{}
+	err := json.Unmarshal([]byte(schema), &raw)
+	if err != nil {
+		return nil, err
+	}
+	parser := avroParser{named: make(map[string]*avroType)}
+	return parser.parse(raw, "")
+}
+
+type avroParser struct {
+	named map[string]*avroType
+}
+
+func (p avroParser) parse(raw interface{}, namespace string) (*avroType, error) {
+	switch v := raw.(type) {
+	case string:
+		if primitiveTypes[v] {
+			return &avroType{kind: v}, nil
+		}
+		if t, ok := p.named[qualifyName(v, namespace)]; ok {
+			return t, nil
+		}
+		if t, ok := p.named[v]; ok {
+			return t, nil
+		}
+		return nil, fmt.Errorf("unknown avro type %q", v)
+	case []interface{}:
+		union := &avroType{kind: "union"}
+		for _, branch := range v {
+			t, err := p.parse(branch, namespace)
+			if err != nil {
+				return nil, err
+			}
+			union.branches = append(union.branches, t)
+		}
+		return union, nil
+	case map[string]interface{}:
+		return p.parseComplex(v, namespace)
+	default:
+		return nil, fmt.Errorf("invalid avro schema %v", raw)
+	}
+}
+
+func (p avroParser) parseComplex(v map[string]interface{}, namespace string) (*avroType, error) {
+	kind, ok := v["type"].(string)
+	if !ok {
+		return p.parse(v["type"], namespace)
+	}
+	switch kind {
+	case "record", "error", "enum", "fixed":
+		name, _ := v["name"].(string)
+		if ns, ok := v["namespace"].(string); ok && !strings.Contains(name, ".") {
+			namespace = ns
+		}
+		fullName := qualifyName(name, namespace)
+		namespace = namespaceOf(fullName)
+		t := &avroType{kind: kind, name: fullName, aliases: p.parseAliases(v["aliases"], namespace)}
+		if kind == "error" {
+			t.kind = "record"
+		}
+		p.named[fullName] = t
+		return t, p.parseNamed(t, v, namespace)
+	case "array":
+		items, err := p.parse(v["items"], namespace)
+		if err != nil {
+			return nil, err
+		}
+		return &avroType{kind: kind, items: items}, nil
+	case "map":
+		values, err := p.parse(v["values"], namespace)
+		if err != nil {
+			return nil, err
+		}
+		return &avroType{kind: kind, values: values}, nil
+	default:
+		// Primitives, possibly annotated with a logical type
+		t, err := p.parse(kind, namespace)
+		if err != nil || !primitiveTypes[kind] {
+			return t, err
+		}
+		parseLogicalType(t, v)
+		return t, nil
+	}
+}
+
+func (p avroParser) parseNamed(t *avroType, v map[string]interface{}, namespace string) error {
+	switch t.kind {
+	case "record":
+		fields, _ := v["fields"].([]interface{})
+		for _, f := range fields {
+			field, _ := f.(map[string]interface{})
+			name, _ := field["name"].(string)
+			typ, err := p.parse(field["type"], namespace)
+			if err != nil {
+				return fmt.Errorf("field %s of %s: %v", name, t.name, err)
+			}
+			defaultValue, hasDefault := field["default"]
+			aliases, _ := field["aliases"].([]interface{})
+			var fieldAliases []string
+			for _, alias := range aliases {
+				if s, ok := alias.(string); ok {
+					fieldAliases = append(fieldAliases, s)
+				}
+			}
+			t.fields = append(t.fields, avroField{
+				name:         name,
+				aliases:      fieldAliases,
+				typ:          typ,
+				defaultValue: defaultValue,
+				hasDefault:   hasDefault,
+			})
+		}
+	case "enum":
+		symbols, _ := v["symbols"].([]interface{})
+		for _, symbol := range symbols {
+			if s, ok := symbol.(string); ok {
+				t.symbols = append(t.symbols, s)
+			}
+		}
+		if d, ok := v["default"].(string); ok {
+			t.enumDefault = &d
+		}
+	case "fixed":
+		size, _ := v["size"].(float64)
+		t.size = int(size)
+		parseLogicalType(t, v)
+	}
+	return nil
+}
+
+func (p avroParser) parseAliases(raw interface{}, namespace string) []string {
+	aliases, _ := raw.([]interface{})
+	var names []string
+	for _, alias := range aliases {
+		if s, ok := alias.(string); ok {
+			names = append(names, qualifyName(s, namespace))
+		}
+	}
+	return names
+}
+
+func qualifyName(name string, namespace string) string {
+	if strings.Contains(name, ".") || namespace == "" {
+		return name
+	}
+	return namespace + "." + name
+}
+
+func namespaceOf(fullName string) string {
+	if i := strings.LastIndex(fullName, "."); i >= 0 {
+		return fullName[:i]
+	}
+	return ""
+}
+
+func shortName(fullName string) string {
+	return fullName[strings.LastIndex(fullName, ".")+1:]
+}
+
+// typeName is the name goavro uses for a union branch, which
+// includes the logical type of unnamed types.
+func (t *avroType) typeName() string {
+	if t.name != "" {
+		return t.name
+	}
+	if t.logicalType != "" {
+		return t.kind + "." + t.logicalType
+	}
+	return t.kind
+}
+
+// namesMatch reports whether data of a named writer type
+// can be read as the named reader type.
+func namesMatch(writer *avroType, reader *avroType) bool {
+	if shortName(writer.name) == shortName(reader.name) {
+		return true
+	}
+	for _, alias := range reader.aliases {
+		if alias == writer.name || shortName(alias) == shortName(writer.name) {
+			return true
+		}
+	}
+	return false
+}
+
+// avroResolver compiles projections between writer and reader
+// schemas. Compiled record projections are memoized so that
+// recursive schemas terminate.
+type avroResolver struct {
+	records map[[2]*avroType]*Projection
+}
+
+// Resolve compiles the projection of values written
+// with the writer schema into the reader schema. It fails when
+// the reader cannot read data written by the writer.
+func Resolve(writerSchema string, readerSchema string) (Projection, error) {
+	writer, err := parseAvroSchema(writerSchema)
+	if err != nil {
+		return nil, fmt.Errorf("invalid writer schema: %v", err)
+	}
+	reader, err := parseAvroSchema(readerSchema)
+	if err != nil {
+		return nil, fmt.Errorf("invalid reader schema: %v", err)
+	}
+	resolver := avroResolver{records: make(map[[2]*avroType]*Projection)}
+	return resolver.resolve(writer, reader)
+}
+
+// promotions convert the native values of the writer types the
+// Avro specification promotes into those of the reader types.
+var promotions = map[[2]string]func(v interface{}) (interface{}, bool){
+	{"int", "long"}: func(v interface{}) (interface{}, bool) {
+		i, ok := v.(int32)
+		return int64(i), ok
+	},
+	{"int", "float"}: func(v interface{}) (interface{}, bool) {
+		i, ok := v.(int32)
+		return float32(i), ok
+	},
+	{"int", "double"}: func(v interface{}) (interface{}, bool) {
+		i, ok := v.(int32)
+		return float64(i), ok
+	},
+	{"long", "float"}: func(v interface{}) (interface{}, bool) {
+		l, ok := v.(int64)
+		return float32(l), ok
+	},
+	{"long", "double"}: func(v interface{}) (interface{}, bool) {
+		l, ok := v.(int64)
+		return float64(l), ok
+	},
+	{"float", "double"}: func(v interface{}) (interface{}, bool) {
+		f, ok := v.(float32)
+		return float64(f), ok
+	},
+	{"string", "bytes"}: func(v interface{}) (interface{}, bool) {
+		s, ok := v.(string)
+		return []byte(s), ok
+	},
+	{"bytes", "string"}: func(v interface{}) (interface{}, bool) {
+		b, ok := v.([]byte)
+		return string(b), ok
+	},
+}
+
+func (r avroResolver) resolve(writer *avroType, reader *avroType) (Projection, error) {
+	if writer.kind == "union" {
+		return r.resolveWriterUnion(writer, reader)
+	}
+	if reader.kind == "union" {
+		return r.resolveReaderUnion(writer, reader)
+	}
+	project, err := r.resolveUnderlying(writer, reader)
+	if err != nil || (writer.logicalType == "" && reader.logicalType == "") {
+		return project, err
+	}
+	return resolveLogical(writer, reader, project)
+}
+
+// resolveUnderlying resolves the types underlying logical types.
+func (r avroResolver) resolveUnderlying(writer *avroType, reader *avroType) (Projection, error) {
+	if writer.kind == reader.kind {
+		return r.resolveSameKind(writer, reader)
+	}
+	promote, ok := promotions[[2]string{writer.kind, reader.kind}]
+	if !ok {
+		return nil, fmt.Errorf("cannot read %s as %s", writer.typeName(), reader.typeName())
+	}
+	return func(v interface{}) (interface{}, error) {
+		value, ok := promote(v)
+		if !ok {
+			return nil, unexpectedNative(v, writer)
+		}
+		return value, nil
+	}, nil
+}
+
+func (r avroResolver) resolveSameKind(writer *avroType, reader *avroType) (Projection, error) {
+	switch writer.kind {
+	case "record":
+		if !namesMatch(writer, reader) {
+			return nil, fmt.Errorf("cannot read record %s as %s", writer.name, reader.name)
+		}
+		return r.resolveRecord(writer, reader)
+	case "enum":
+		if !namesMatch(writer, reader) {
+			return nil, fmt.Errorf("cannot read enum %s as %s", writer.name, reader.name)
+		}
+		return resolveEnum(writer, reader), nil
+	case "fixed":
+		if !namesMatch(writer, reader) || writer.size != reader.size {
+			return nil, fmt.Errorf("cannot read fixed %s(%d) as %s(%d)", writer.name, writer.size, reader.name, reader.size)
+		}
+		return identity, nil
+	case "array":
+		items, err := r.resolve(writer.items, reader.items)
+		if err != nil {
+			return nil, fmt.Errorf("array items: %v", err)
+		}
+		return func(v interface{}) (interface{}, error) {
+			in, ok := v.([]interface{})
+			if !ok {
+				return nil, unexpectedNative(v, writer)
+			}
+			out := make([]interface{}, len(in))
+			for i, item := range in {
+				var err error
+				out[i], err = items(item)
+				if err != nil {
+					return nil, err
+				}
+			}
+			return out, nil
+		}, nil
+	case "map":
+		values, err := r.resolve(writer.values, reader.values)
+		if err != nil {
+			return nil, fmt.Errorf("map values: %v", err)
+		}
+		return func(v interface{}) (interface{}, error) {
+			in, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, unexpectedNative(v, writer)
+			}
+			out := make(map[string]interface{}, len(in))
+			for key, value := range in {
+				var err error
+				out[key], err = values(value)
+				if err != nil {
+					return nil, err
+				}
+			}
+			return out, nil
+		}, nil
+	default:
+		return identity, nil
+	}
+}
+
+type fieldProjection struct {
+	from    string
+	to      string
+	project Projection
+	value   interface{}
+}
+
+func (r avroResolver) resolveRecord(writer *avroType, reader *avroType) (Projection, error) {
+	key := [2]*avroType{writer, reader}
+	if compiled, ok := r.records[key]; ok {
+		return func(v interface{}) (interface{}, error) { return (*compiled)(v) }, nil
+	}
+	compiled := new(Projection)
+	r.records[key] = compiled
+	fields, err := r.resolveFields(writer, reader)
+	if err != nil {
+		delete(r.records, key)
+		return nil, err
+	}
+	*compiled = func(v interface{}) (interface{}, error) {
+		in, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, unexpectedNative(v, writer)
+		}
+		out := make(map[string]interface{}, len(fields))
+		for _, field := range fields {
+			if field.project == nil {
+				out[field.to] = field.value
+				continue
+			}
+			value, err := field.project(in[field.from])
+			if err != nil {
+				return nil, err
+			}
+			out[field.to] = value
+		}
+		return out, nil
+	}
+	return *compiled, nil
+}
+
+func (r avroResolver) resolveFields(writer *avroType, reader *avroType) ([]fieldProjection, error) {
+	var fields []fieldProjection
+	for _, readerField := range reader.fields {
+		writerField := findWriterField(writer, readerField)
+		switch {
+		case writerField != nil:
+			project, err := r.resolve(writerField.typ, readerField.typ)
+			if err != nil {
+				return nil, fmt.Errorf("field %s of %s: %v", readerField.name, reader.name, err)
+			}
+			fields = append(fields, fieldProjection{from: writerField.name, to: readerField.name, project: project})
+		case readerField.hasDefault:
+			value, err := defaultToNative(readerField.typ, readerField.defaultValue)
+			if err != nil {
+				return nil, fmt.Errorf("default of field %s of %s: %v", readerField.name, reader.name, err)
+			}
+			fields = append(fields, fieldProjection{to: readerField.name, value: value})
+		default:
+			return nil, fmt.Errorf("field %s of %s is missing from writer schema and has no default", readerField.name, reader.name)
+		}
+	}
+	return fields, nil
+}
+
+func findWriterField(writer *avroType, readerField avroField) *avroField {
+	for i, writerField := range writer.fields {
+		if writerField.name == readerField.name {
+			return &writer.fields[i]
+		}
+	}
+	for i, writerField := range writer.fields {
+		for _, alias := range readerField.aliases {
+			if writerField.name == alias {
+				return &writer.fields[i]
+			}
+		}
+	}
+	return nil
+}
+
+func resolveEnum(writer *avroType, reader *avroType) Projection {
+	symbols := make(map[string]bool, len(reader.symbols))
+	for _, symbol := range reader.symbols {
+		symbols[symbol] = true
+	}
+	return func(v interface{}) (interface{}, error) {
+		symbol, ok := v.(string)
+		if !ok {
+			return nil, unexpectedNative(v, writer)
+		}
+		if symbols[symbol] {
+			return symbol, nil
+		}
+		if reader.enumDefault != nil {
+			return *reader.enumDefault, nil
+		}
+		return nil, fmt.Errorf("symbol %s of enum %s is unknown to reader schema", symbol, writer.name)
+	}
+}
+
+func (r avroResolver) resolveWriterUnion(writer *avroType, reader *avroType) (Projection, error) {
+	branches := make(map[string]Projection, len(writer.branches))
+	var errs []string
+	for _, branch := range writer.branches {
+		project, err := r.resolve(branch, reader)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		branches[branch.typeName()] = project
+	}
+	if len(branches) == 0 {
+		return nil, fmt.Errorf("no branch of writer union can be read: %s", strings.Join(errs, "; "))
+	}
+	return func(v interface{}) (interface{}, error) {
+		name, value := "null", interface{}(nil)
+		if v != nil {
+			in, ok := v.(map[string]interface{})
+			if !ok || len(in) != 1 {
+				return nil, unexpectedNative(v, writer)
+			}
+			for branchName, branchValue := range in {
+				name, value = branchName, branchValue
+			}
+		}
+		project, ok := branches[name]
+		if !ok {
+			return nil, fmt.Errorf("union branch %s cannot be read with reader schema", name)
+		}
+		return project(value)
+	}, nil
+}
+
+func (r avroResolver) resolveReaderUnion(writer *avroType, reader *avroType) (Projection, error) {
+	branch := matchReaderBranch(writer, reader)
+	if branch == nil {
+		return nil, fmt.Errorf("cannot read %s as any branch of reader union", writer.typeName())
+	}
+	project, err := r.resolve(writer, branch)
+	if err != nil {
+		return nil, err
+	}
+	if branch.kind == "null" {
+		return project, nil
+	}
+	name := branch.typeName()
+	return func(v interface{}) (interface{}, error) {
+		value, err := project(v)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{name: value}, nil
+	}, nil
+}
+
+// matchReaderBranch picks the first reader branch with the same
+// type and logical type as the writer, then the first one with the
+// same type, then the first one the writer promotes to.
+func matchReaderBranch(writer *avroType, reader *avroType) *avroType {
+	for _, branch := range reader.branches {
+		if branch.kind == writer.kind && branch.logicalType == writer.logicalType && (branch.name == "" || namesMatch(writer, branch)) {
+			return branch
+		}
+	}
+	for _, branch := range reader.branches {
+		if branch.kind == writer.kind && (branch.name == "" || namesMatch(writer, branch)) {
+			return branch
+		}
+	}
+	resolver := avroResolver{records: make(map[[2]*avroType]*Projection)}
+	for _, branch := range reader.branches {
+		if branch.kind == writer.kind || branch.kind == "union" {
+			continue
+		}
+		if _, err := resolver.resolve(writer, branch); err == nil {
+			return branch
+		}
+	}
+	return nil
+}
+
+func identity(v interface{}) (interface{}, error) {
+	return v, nil
+}
+
+// unexpectedNative is returned by projections given another native
+// value than goavro decodes the writer type into.
+func unexpectedNative(v interface{}, writer *avroType) error {
+	return fmt.Errorf("cannot read %T as %s", v, writer.typeName())
+}
+
+// defaultToNative converts the JSON default of a field into
+// the native value goavro expects for the field type.
+func defaultToNative(t *avroType, v interface{}) (interface{}, error) {
+	if t.logicalType != "" {
+		// Defaults of logical types are those of their underlying type
+		underlying := *t
+		underlying.logicalType = ""
+		value, err := defaultToNative(&underlying, v)
+		if err != nil {
+			return nil, err
+		}
+		return fromUnderlying(t)(value)
+	}
+	switch t.kind {
+	case "null":
+		if v != nil {
+			return nil, errors.New("null default must be null")
+		}
+		return nil, nil
+	case "boolean":
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+	case "int":
+		if f, ok := v.(float64); ok {
+			return int32(f), nil
+		}
+	case "long":
+		if f, ok := v.(float64); ok {
+			return int64(f), nil
+		}
+	case "float":
+		if f, ok := v.(float64); ok {
+			return float32(f), nil
+		}
+	case "double":
+		if f, ok := v.(float64); ok {
+			return f, nil
+		}
+	case "string", "enum":
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+	case "bytes", "fixed":
+		// Bytes defaults are strings whose code points are the byte values
+		if s, ok := v.(string); ok {
+			var b []byte
+			for _, r := range s {
+				b = append(b, byte(r))
+			}
+			return b, nil
+		}
+	case "array":
+		if items, ok := v.([]interface{}); ok {
+			out := make([]interface{}, len(items))
+			for i, item := range items {
+				var err error
+				out[i], err = defaultToNative(t.items, item)
+				if err != nil {
+					return nil, err
+				}
+			}
+			return out, nil
+		}
+	case "map":
+		if values, ok := v.(map[string]interface{}); ok {
+			out := make(map[string]interface{}, len(values))
+			for key, value := range values {
+				var err error
+				out[key], err = defaultToNative(t.values, value)
+				if err != nil {
+					return nil, err
+				}
+			}
+			return out, nil
+		}
+	case "record":
+		if values, ok := v.(map[string]interface{}); ok {
+			out := make(map[string]interface{}, len(t.fields))
+			for _, field := range t.fields {
+				value, ok := values[field.name]
+				if !ok {
+					if !field.hasDefault {
+						return nil, fmt.Errorf("field %s has no default", field.name)
+					}
+					value = field.defaultValue
+				}
+				var err error
+				out[field.name], err = defaultToNative(field.typ, value)
+				if err != nil {
+					return nil, err
+				}
+			}
+			return out, nil
+		}
+	case "union":
+		// Union defaults always belong to the first branch
+		first := t.branches[0]
+		value, err := defaultToNative(first, v)
+		if err != nil || first.kind == "null" {
+			return value, err
+		}
+		return map[string]interface{}{first.typeName(): value}, nil
+	}
+	return nil, fmt.Errorf("invalid default %v for %s", v, t.typeName())
+}
@@ -0,0 +1,225 @@
+package avro
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+type resolutionTest struct {
+	name   string
+	writer string
+	reader string
+	// value is written with the writer schema and want is the value
+	// expected when it is read with the reader schema
+	value interface{}
+	want  interface{}
+	// err is whether resolving the schemas or projecting the value fails
+	err bool
+}
+
+func runResolutionTests(t *testing.T, tests []resolutionTest) {
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			writer, err := goavro.NewCodec(test.writer)
+			if err != nil {
+				t.Fatalf("invalid writer schema: %v", err)
+			}
+			reader, err := goavro.NewCodec(test.reader)
+			if err != nil {
+				t.Fatalf("invalid reader schema: %v", err)
+			}
+			// Values are projected as goavro decodes them
+			binary, err := writer.BinaryFromNative(nil, test.value)
+			if err != nil {
+				t.Fatalf("cannot encode value: %v", err)
+			}
+			written, _, err := writer.NativeFromBinary(binary)
+			if err != nil {
+				t.Fatalf("cannot decode value: %v", err)
+			}
+			project, err := Resolve(test.writer, test.reader)
+			if err == nil {
+				written, err = project(written)
+			}
+			if test.err {
+				if err == nil {
+					t.Fatalf("read %v, want an error", written)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("cannot read value: %v", err)
+			}
+			// Comparing encodings also checks that the reader codec
+			// accepts the value
+			got, err := reader.BinaryFromNative(nil, written)
+			if err != nil {
+				t.Fatalf("cannot encode %#v with reader schema: %v", written, err)
+			}
+			want, err := reader.BinaryFromNative(nil, test.want)
+			if err != nil {
+				t.Fatalf("cannot encode expected value: %v", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("read %#v, want %#v", written, test.want)
+			}
+		})
+	}
+}
+
+func TestResolvePromotions(t *testing.T) {
+	runResolutionTests(t, []resolutionTest{
+		{name: "int to long", writer: `"int"`, reader: `"long"`, value: int32(7), want: int64(7)},
+		{name: "int to float", writer: `"int"`, reader: `"float"`, value: int32(7), want: float32(7)},
+		{name: "int to double", writer: `"int"`, reader: `"double"`, value: int32(7), want: float64(7)},
+		{name: "long to float", writer: `"long"`, reader: `"float"`, value: int64(7), want: float32(7)},
+		{name: "long to double", writer: `"long"`, reader: `"double"`, value: int64(7), want: float64(7)},
+		{name: "float to double", writer: `"float"`, reader: `"double"`, value: float32(1.5), want: float64(1.5)},
+		{name: "string to bytes", writer: `"string"`, reader: `"bytes"`, value: "id", want: []byte("id")},
+		{name: "bytes to string", writer: `"bytes"`, reader: `"string"`, value: []byte("id"), want: "id"},
+		{name: "array items", writer: `{"type": "array", "items": "int"}`, reader: `{"type": "array", "items": "long"}`,
+			value: []interface{}{int32(1), int32(2)}, want: []interface{}{int64(1), int64(2)}},
+		{name: "long to int", writer: `"long"`, reader: `"int"`, value: int64(7), err: true},
+		{name: "string to int", writer: `"string"`, reader: `"int"`, value: "7", err: true},
+	})
+}
+
+func TestResolvePromotionOfUnexpectedNative(t *testing.T) {
+	project, err := Resolve(`"int"`, `"long"`)
+	if err != nil {
+		t.Fatalf("cannot resolve schemas: %v", err)
+	}
+	if _, err := project("7"); err == nil {
+		t.Error("promoted a string")
+	}
+}
+
+func TestResolveDefaults(t *testing.T) {
+	writer := `{"type": "record", "name": "User", "fields": [{"name": "name", "type": "string"}]}`
+	runResolutionTests(t, []resolutionTest{
+		{
+			name:   "defaults of added fields",
+			writer: writer,
+			reader: `{"type": "record", "name": "User", "fields": [
+				{"name": "name", "type": "string"},
+				{"name": "age", "type": "int", "default": 18},
+				{"name": "nickname", "type": ["null", "string"], "default": null},
+				{"name": "role", "type": {"type": "enum", "name": "Role", "symbols": ["MEMBER", "ADMIN"]}, "default": "MEMBER"},
+				{"name": "tags", "type": {"type": "array", "items": "string"}, "default": ["new"]},
+				{"name": "token", "type": "bytes", "default": "ÿ"},
+				{"name": "address", "type": {"type": "record", "name": "Address", "fields": [
+					{"name": "city", "type": "string", "default": "Jakarta"}
+				]}, "default": {}}
+			]}`,
+			value: map[string]interface{}{"name": "ana"},
+			want: map[string]interface{}{
+				"name":     "ana",
+				"age":      int32(18),
+				"nickname": nil,
+				"role":     "MEMBER",
+				"tags":     []interface{}{"new"},
+				"token":    []byte{0xff},
+				"address":  map[string]interface{}{"city": "Jakarta"},
+			},
+		},
+		{
+			name:   "renamed field",
+			writer: writer,
+			reader: `{"type": "record", "name": "User", "fields": [{"name": "fullName", "type": "string", "aliases": ["name"]}]}`,
+			value:  map[string]interface{}{"name": "ana"},
+			want:   map[string]interface{}{"fullName": "ana"},
+		},
+		{
+			name:   "field without default",
+			writer: writer,
+			reader: `{"type": "record", "name": "User", "fields": [{"name": "name", "type": "string"}, {"name": "age", "type": "int"}]}`,
+			value:  map[string]interface{}{"name": "ana"},
+			err:    true,
+		},
+	})
+}
+
+func TestResolveEnums(t *testing.T) {
+	writer := `{"type": "enum", "name": "Role", "symbols": ["MEMBER", "ADMIN", "OWNER"]}`
+	withDefault := `{"type": "enum", "name": "Role", "symbols": ["MEMBER", "ADMIN"], "default": "MEMBER"}`
+	withoutDefault := `{"type": "enum", "name": "Role", "symbols": ["MEMBER", "ADMIN"]}`
+	runResolutionTests(t, []resolutionTest{
+		{name: "known symbol", writer: writer, reader: withoutDefault, value: "ADMIN", want: "ADMIN"},
+		{name: "unknown symbol with default", writer: writer, reader: withDefault, value: "OWNER", want: "MEMBER"},
+		{name: "unknown symbol without default", writer: writer, reader: withoutDefault, value: "OWNER", err: true},
+		{name: "another enum", writer: writer, reader: `{"type": "enum", "name": "Status", "symbols": ["MEMBER"]}`, value: "MEMBER", err: true},
+	})
+}
+
+func TestResolveUnions(t *testing.T) {
+	runResolutionTests(t, []resolutionTest{
+		{name: "same union", writer: `["null", "string"]`, reader: `["null", "string"]`,
+			value: goavro.Union("string", "ana"), want: goavro.Union("string", "ana")},
+		{name: "null branch", writer: `["null", "string"]`, reader: `["null", "string"]`, value: nil, want: nil},
+		{name: "into union", writer: `"string"`, reader: `["null", "string"]`, value: "ana", want: goavro.Union("string", "ana")},
+		{name: "out of union", writer: `["null", "string"]`, reader: `"string"`,
+			value: goavro.Union("string", "ana"), want: "ana"},
+		{name: "null out of union", writer: `["null", "string"]`, reader: `"string"`, value: nil, err: true},
+		{name: "promoted branch", writer: `["null", "int"]`, reader: `["null", "long"]`,
+			value: goavro.Union("int", int32(7)), want: goavro.Union("long", int64(7))},
+		{name: "exact branch before promotion", writer: `"long"`, reader: `["null", "double", "long"]`,
+			value: int64(7), want: goavro.Union("long", int64(7))},
+		{name: "named branch", writer: `{"type": "record", "name": "User", "fields": [{"name": "name", "type": "string"}]}`,
+			reader: `["null", {"type": "record", "name": "User", "fields": [{"name": "name", "type": "string"}]}]`,
+			value:  map[string]interface{}{"name": "ana"}, want: goavro.Union("User", map[string]interface{}{"name": "ana"})},
+		{name: "no matching branch", writer: `"boolean"`, reader: `["null", "string"]`, value: true, err: true},
+	})
+}
+
+func TestResolveLogicalTypes(t *testing.T) {
+	timestampMillis := `{"type": "long", "logicalType": "timestamp-millis"}`
+	timestampMicros := `{"type": "long", "logicalType": "timestamp-micros"}`
+	date := `{"type": "int", "logicalType": "date"}`
+	timeMillis := `{"type": "int", "logicalType": "time-millis"}`
+	decimal := `{"type": "bytes", "logicalType": "decimal", "precision": 10, "scale": 2}`
+	instant := time.Date(2024, 6, 1, 12, 30, 0, 0, time.UTC)
+	runResolutionTests(t, []resolutionTest{
+		{name: "timestamp", writer: timestampMillis, reader: timestampMillis, value: instant, want: instant},
+		{name: "optional timestamp", writer: `["null", ` + timestampMillis + `]`, reader: `["null", ` + timestampMillis + `]`,
+			value: goavro.Union("long.timestamp-millis", instant), want: goavro.Union("long.timestamp-millis", instant)},
+		{name: "timestamp into union", writer: timestampMillis, reader: `["null", "long", ` + timestampMillis + `]`,
+			value: instant, want: goavro.Union("long.timestamp-millis", instant)},
+		{name: "timestamp out of union", writer: `["null", ` + timestampMicros + `]`, reader: timestampMicros,
+			value: goavro.Union("long.timestamp-micros", instant), want: instant},
+		{name: "timestamp as long", writer: timestampMillis, reader: `"long"`, value: instant, want: instant.UnixNano() / int64(time.Millisecond)},
+		{name: "long as timestamp", writer: `"long"`, reader: timestampMillis, value: instant.UnixNano() / int64(time.Millisecond), want: instant},
+		{name: "promoted int as timestamp", writer: `"int"`, reader: timestampMillis, value: int32(1000), want: time.Unix(1, 0).UTC()},
+		{name: "date", writer: date, reader: date, value: instant.Truncate(24 * time.Hour), want: instant.Truncate(24 * time.Hour)},
+		{name: "date as int", writer: date, reader: `"int"`, value: time.Unix(0, 0).AddDate(0, 0, 3), want: int32(3)},
+		{name: "time of day as int", writer: timeMillis, reader: `"int"`, value: 90 * time.Second, want: int32(90000)},
+		{name: "decimal", writer: decimal, reader: decimal, value: big.NewRat(1234, 100), want: big.NewRat(1234, 100)},
+		{name: "negative decimal as bytes", writer: decimal, reader: `"bytes"`, value: big.NewRat(-1, 100), want: []byte{0xff}},
+		{name: "bytes as decimal", writer: `"bytes"`, reader: decimal, value: []byte{0x04, 0xd2}, want: big.NewRat(1234, 100)},
+		{name: "decimal of another scale", writer: decimal, reader: `{"type": "bytes", "logicalType": "decimal", "precision": 10, "scale": 3}`,
+			value: big.NewRat(1234, 100), err: true},
+		{name: "unsupported logical type", writer: `{"type": "string", "logicalType": "uuid"}`, reader: `"string"`,
+			value: "0a8b", want: "0a8b"},
+	})
+}
+
+// goavro refuses codecs with defaults of logical types, which the
+// specification gives as values of their underlying type
+func TestResolveLogicalTypeDefault(t *testing.T) {
+	project, err := Resolve(`{"type": "record", "name": "Event", "fields": []}`,
+		`{"type": "record", "name": "Event", "fields": [{"name": "at", "type": {"type": "long", "logicalType": "timestamp-millis"}, "default": 1000}]}`)
+	if err != nil {
+		t.Fatalf("cannot resolve schemas: %v", err)
+	}
+	event, err := project(map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("cannot read event: %v", err)
+	}
+	at, ok := event.(map[string]interface{})["at"].(time.Time)
+	if !ok || !at.Equal(time.Unix(1, 0)) {
+		t.Errorf("default is %#v", at)
+	}
+}
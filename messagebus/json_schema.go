@@ -0,0 +1,101 @@
+package messagebus
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+
+	jsonschemagen "github.com/invopop/jsonschema"
+	"github.com/kata-ai/messagebus-golang-kafka/messagebus/schemaregistry"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// jsonSchemaRegistry generates the JSON Schemas of the Go types
+// values are sent as, and compiles the JSON Schemas registered in
+// Schema Registry so that values are validated against them.
+type jsonSchemaRegistry struct {
+	reflector     *jsonschemagen.Reflector
+	generated     map[reflect.Type]string
+	generatedLock sync.RWMutex
+	compiled      map[int]*jsonschema.Schema
+	compiledLock  sync.RWMutex
+}
+
+func newJSONSchemaRegistry() *jsonSchemaRegistry {
+	return &jsonSchemaRegistry{
+		reflector: &jsonschemagen.Reflector{DoNotReference: true},
+		generated: make(map[reflect.Type]string),
+		compiled:  make(map[int]*jsonschema.Schema),
+	}
+}
+
+// schemaOf returns the JSON Schema of the type of the value, generated
+// from its json and jsonschema struct tags. The schema is titled with
+// the name of the type, which subject strategies use as record name.
+func (r *jsonSchemaRegistry) schemaOf(value interface{}) (string, error) {
+	t := reflect.TypeOf(value)
+	if t == nil {
+		return "", fmt.Errorf("cannot generate the JSON Schema of a nil value")
+	}
+	r.generatedLock.RLock()
+	schema, ok := r.generated[t]
+	r.generatedLock.RUnlock()
+	if ok {
+		return schema, nil
+	}
+	reflected := r.reflector.Reflect(value)
+	if reflected.Title == "" {
+		elem := t
+		for elem.Kind() == reflect.Ptr {
+			elem = elem.Elem()
+		}
+		reflected.Title = elem.Name()
+	}
+	schemaBytes, err := json.Marshal(reflected)
+	if err != nil {
+		return "", err
+	}
+	schema = string(schemaBytes)
+	r.generatedLock.Lock()
+	r.generated[t] = schema
+	r.generatedLock.Unlock()
+	return schema, nil
+}
+
+// validate checks a JSON payload against a registered JSON Schema,
+// compiling each schema only once.
+func (r *jsonSchemaRegistry) validate(schema *schemaregistry.Schema, payload []byte) error {
+	r.compiledLock.RLock()
+	compiled := r.compiled[schema.ID()]
+	r.compiledLock.RUnlock()
+	if compiled == nil {
+		url := "schema-" + strconv.Itoa(schema.ID()) + ".json"
+		compiler := jsonschema.NewCompiler()
+		err := compiler.AddResource(url, bytes.NewReader([]byte(schema.Schema())))
+		if err != nil {
+			return fmt.Errorf("invalid JSON Schema %d: %v", schema.ID(), err)
+		}
+		compiled, err = compiler.Compile(url)
+		if err != nil {
+			return fmt.Errorf("invalid JSON Schema %d: %v", schema.ID(), err)
+		}
+		r.compiledLock.Lock()
+		r.compiled[schema.ID()] = compiled
+		r.compiledLock.Unlock()
+	}
+	var instance interface{}
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	err := decoder.Decode(&instance)
+	if err != nil {
+		return err
+	}
+	err = compiled.Validate(instance)
+	if err != nil {
+		return fmt.Errorf("value does not match JSON Schema %d: %v", schema.ID(), err)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package messagebus
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// keyHeaderPrefix prefixes the headers the library sends, such as the
+// headers carrying the fields of the message key, which are named
+// after their JSON names.
+const keyHeaderPrefix = "messagebus-"
+
+// attributeHeaderPrefix prefixes the headers carrying the custom
+// attributes of the message key, which are named after them.
+const attributeHeaderPrefix = keyHeaderPrefix + "attribute-"
+
+// messageKeyHeaders carries the fields of a message key in headers.
+// The value subject and optional fields are left out when empty.
+func messageKeyHeaders(key *MessageKey) []kafka.Header {
+	if key == nil {
+		return nil
+	}
+	var headers []kafka.Header
+	add := func(name string, value string) {
+		headers = append(headers, kafka.Header{Key: keyHeaderPrefix + name, Value: []byte(value)})
+	}
+	if key.ValueSubject != "" {
+		add("valueSubject", key.ValueSubject)
+	}
+	add("messageId", key.MessageId)
+	add("correlationId", key.CorrelationId)
+	add("conversationId", key.ConversationId)
+	add("replyTopic", key.ReplyTopic)
+	add("originService", key.OriginService)
+	add("originHostname", key.OriginHostname)
+	add("messageBusVersion", key.MessageBusVersion)
+	add("timestamp", strconv.FormatInt(key.Timestamp, 10))
+	if key.TenantId != "" {
+		add("tenantId", key.TenantId)
+	}
+	if key.TraceParent != "" {
+		add("traceParent", key.TraceParent)
+	}
+	if key.TraceState != "" {
+		add("traceState", key.TraceState)
+	}
+	if key.Priority != 0 {
+		add("priority", strconv.FormatInt(int64(key.Priority), 10))
+	}
+	if key.ExpiresAt != 0 {
+		add("expiresAt", strconv.FormatInt(key.ExpiresAt, 10))
+	}
+	names := make([]string, 0, len(key.Attributes))
+	for name := range key.Attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		add("attribute-"+name, key.Attributes[name])
+	}
+	return headers
+}
+
+// messageKeyFromHeaders reads the message key carried in headers,
+// returning nil for messages sent without one, such as messages
+// with an Avro message key or produced by other systems. Other
+// headers of the library, such as chunk headers, are ignored.
+func messageKeyFromHeaders(headers []kafka.Header) (*MessageKey, error) {
+	key := &MessageKey{}
+	found := false
+	for _, header := range headers {
+		if !strings.HasPrefix(header.Key, keyHeaderPrefix) {
+			continue
+		}
+		value := string(header.Value)
+		if strings.HasPrefix(header.Key, attributeHeaderPrefix) {
+			if key.Attributes == nil {
+				key.Attributes = make(map[string]string)
+			}
+			key.Attributes[strings.TrimPrefix(header.Key, attributeHeaderPrefix)] = value
+			found = true
+			continue
+		}
+		switch strings.TrimPrefix(header.Key, keyHeaderPrefix) {
+		case "valueSubject":
+			key.ValueSubject = value
+		case "messageId":
+			key.MessageId = value
+		case "correlationId":
+			key.CorrelationId = value
+		case "conversationId":
+			key.ConversationId = value
+		case "replyTopic":
+			key.ReplyTopic = value
+		case "originService":
+			key.OriginService = value
+		case "originHostname":
+			key.OriginHostname = value
+		case "messageBusVersion":
+			key.MessageBusVersion = value
+		case "timestamp":
+			timestamp, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid message key timestamp header: %v", err)
+			}
+			key.Timestamp = timestamp
+		case "tenantId":
+			key.TenantId = value
+		case "traceParent":
+			key.TraceParent = value
+		case "traceState":
+			key.TraceState = value
+		case "priority":
+			priority, err := strconv.ParseInt(value, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid message key priority header: %v", err)
+			}
+			key.Priority = int32(priority)
+		case "expiresAt":
+			expiresAt, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid message key expiresAt header: %v", err)
+			}
+			key.ExpiresAt = expiresAt
+		default:
+			continue
+		}
+		found = true
+	}
+	if !found {
+		return nil, nil
+	}
+	return key, nil
+}
@@ -0,0 +1,89 @@
+package messagebus
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/kata-ai/messagebus-golang-kafka/messagebus/schemaregistry"
+)
+
+type keyedOrder struct {
+	Id string `json:"id"`
+}
+
+func TestMessageKeyFormats(t *testing.T) {
+	registry := schemaregistry.NewMockClient()
+	legacy, err := NewSerializerWithClient(registry, TOPIC_NAME_STRATEGY)
+	if err != nil {
+		t.Fatalf("cannot create serializer: %v", err)
+	}
+	envelope, err := NewSerializerWithClient(registry, TOPIC_NAME_STRATEGY, WithHeaderEnvelope(true))
+	if err != nil {
+		t.Fatalf("cannot create serializer: %v", err)
+	}
+	tests := []struct {
+		name       string
+		serializer *Serializer
+		recordKey  []byte
+	}{
+		{"avro message key", legacy, nil},
+		{"header envelope", envelope, []byte("order-1")},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			key, err := NewMessageKey("order-service", WithAttribute("currency", "IDR"))
+			if err != nil {
+				t.Fatalf("cannot create message key: %v", err)
+			}
+			record := NewProducerRecord(key, &keyedOrder{Id: "order-1"})
+			record.RecordKey = []byte("order-1")
+			serialized, err := test.serializer.Serialize("orders", record)
+			if err != nil {
+				t.Fatalf("cannot serialize record: %v", err)
+			}
+			if test.recordKey != nil && !reflect.DeepEqual(serialized.Key, test.recordKey) {
+				t.Errorf("record key is %q", serialized.Key)
+			}
+			topic := "orders"
+			message := &kafka.Message{
+				TopicPartition: kafka.TopicPartition{Topic: &topic},
+				Key:            serialized.Key,
+				Value:          serialized.Value,
+				// Other headers of the library are not message key fields
+				Headers: append(serialized.Headers, kafka.Header{Key: keyHeaderPrefix + "signature", Value: []byte("signature")}),
+			}
+			// Both formats are read whatever the serializer writes
+			for _, deserializer := range []*Serializer{legacy, envelope} {
+				consumed, err := deserializer.Deserialize(message)
+				if err != nil {
+					t.Fatalf("cannot deserialize record: %v", err)
+				}
+				if !reflect.DeepEqual(consumed.Key, key) {
+					t.Errorf("key is %+v, want %+v", consumed.Key, key)
+				}
+				if !reflect.DeepEqual(consumed.RecordKey, test.recordKey) {
+					t.Errorf("record key is %q, want %q", consumed.RecordKey, test.recordKey)
+				}
+			}
+		})
+	}
+}
+
+func TestMessageWithoutKey(t *testing.T) {
+	serializer, err := NewSerializerWithClient(schemaregistry.NewMockClient(), TOPIC_NAME_STRATEGY)
+	if err != nil {
+		t.Fatalf("cannot create serializer: %v", err)
+	}
+	topic := "orders"
+	consumed, err := serializer.Deserialize(&kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic},
+		Headers:        []kafka.Header{{Key: keyHeaderPrefix + "signature", Value: []byte("signature")}},
+	})
+	if err != nil {
+		t.Fatalf("cannot deserialize record: %v", err)
+	}
+	if consumed.Key != nil || consumed.RecordKey != nil {
+		t.Errorf("key is %+v, record key is %q, want none", consumed.Key, consumed.RecordKey)
+	}
+}
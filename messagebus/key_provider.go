@@ -0,0 +1,126 @@
+package messagebus
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// StaticKeyProvider is an IKeyProvider wrapping data keys with AES
+// keys held in memory. Keys are rotated by adding a new key and
+// making it current, while keeping the previous ones so that the
+// messages they wrapped remain readable.
+type StaticKeyProvider struct {
+	currentKeyId string
+	keys         map[string][]byte
+	lock         sync.RWMutex
+}
+
+// NewStaticKeyProvider creates a provider with AES keys of 16, 24
+// or 32 bytes by key ID, wrapping data keys with the current one.
+func NewStaticKeyProvider(currentKeyId string, keys map[string][]byte) (*StaticKeyProvider, error) {
+	provider := &StaticKeyProvider{keys: make(map[string][]byte)}
+	for keyId, key := range keys {
+		err := provider.AddKey(keyId, key)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err := provider.SetCurrentKey(currentKeyId)
+	if err != nil {
+		return nil, err
+	}
+	return provider, nil
+}
+
+// NewFileKeyProvider creates a provider with the keys of a JSON key
+// file, holding the current key ID and base64-encoded AES keys:
+//
+// 		{
+// 			"current": "2024-06",
+// 			"keys": {
+// 				"2024-01": "q3Y2...",
+// 				"2024-06": "Zm9v..."
+// 			}
+// 		}
+func NewFileKeyProvider(path string) (*StaticKeyProvider, error) {
+	fileBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var keyFile struct {
+		Current string            `json:"current"`
+		Keys    map[string]string `json:"keys"`
+	}
+	err = json.Unmarshal(fileBytes, &keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key file %s: %v", path, err)
+	}
+	keys := make(map[string][]byte)
+	for keyId, encoded := range keyFile.Keys {
+		keys[keyId], err = base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %s in key file %s: %v", keyId, path, err)
+		}
+	}
+	return NewStaticKeyProvider(keyFile.Current, keys)
+}
+
+// AddKey adds a key, which can unwrap data keys right away and wrap
+// them once it is made current.
+func (p *StaticKeyProvider) AddKey(keyId string, key []byte) error {
+	switch len(key) {
+	case 16, 24, 32:
+	default:
+		return fmt.Errorf("key %s must have 16, 24 or 32 bytes", keyId)
+	}
+	p.lock.Lock()
+	p.keys[keyId] = key
+	p.lock.Unlock()
+	return nil
+}
+
+// SetCurrentKey makes a key the one new data keys are wrapped with.
+func (p *StaticKeyProvider) SetCurrentKey(keyId string) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if _, ok := p.keys[keyId]; !ok {
+		return fmt.Errorf("unknown key %s", keyId)
+	}
+	p.currentKeyId = keyId
+	return nil
+}
+
+func (p *StaticKeyProvider) CurrentKeyId() (string, error) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.currentKeyId, nil
+}
+
+func (p *StaticKeyProvider) WrapKey(keyId string, dataKey []byte) ([]byte, error) {
+	key, err := p.key(keyId)
+	if err != nil {
+		return nil, err
+	}
+	return sealAESGCM(key, dataKey, []byte(keyId))
+}
+
+func (p *StaticKeyProvider) UnwrapKey(keyId string, wrappedKey []byte) ([]byte, error) {
+	key, err := p.key(keyId)
+	if err != nil {
+		return nil, err
+	}
+	return openAESGCM(key, wrappedKey, []byte(keyId))
+}
+
+func (p *StaticKeyProvider) key(keyId string) ([]byte, error) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	key, ok := p.keys[keyId]
+	if !ok {
+		return nil, fmt.Errorf("unknown key %s", keyId)
+	}
+	return key, nil
+}
@@ -1,25 +1,42 @@
 package messagebus
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"strings"
 	"time"
 
+	"github.com/actgardner/gogen-avro/v7/container"
 	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/kata-ai/messagebus-golang-kafka/messagebus/schemaregistry"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/proto"
 )
 
 type MessageBus struct {
-	Producer       *kafka.Producer
-	Consumer       *kafka.Consumer
-	Handlers       map[string]Handler
-	Serializer     ISerializer
-	Subscriptions  []string
-	stopChan       chan bool
-	rpcTimeoutMs   int
-	producerConfig *ProducerConfiguration
-	consumerConfig *ConsumerConfiguration
+	Producer         *kafka.Producer
+	deliver          func(message *kafka.Message) (kafka.TopicPartition, error)
+	Consumer         *kafka.Consumer
+	Handlers         map[string]Handler
+	Serializer       ISerializer
+	Subscriptions    []string
+	stopChan         chan bool
+	closing          chan struct{}
+	rpcTimeoutMs     int
+	producerConfig   *ProducerConfiguration
+	consumerConfig   *ConsumerConfiguration
+	optionErrors     []error
+	startupSchemas   []startupSchemas
+	topicSerializers map[string]ISerializer
+	claimChecks      []*ClaimCheckSerializer
+	expiredCount     int64
+	dedupStore       IDedupStore
+	chunks           *chunkAssembler
+	tracer           trace.Tracer
 }
 
 type MessageBusOption func(m *MessageBus)
@@ -43,13 +60,25 @@ func NewMessageBus(brokerList []string, schemaRegistry string, strategy SubjectS
 	}
 
 	var c *kafka.Consumer
+	var chunks *chunkAssembler
 	if consumerConfig != nil {
+		err = consumerConfig.validateExpiry(p)
+		if err == nil {
+			err = consumerConfig.validateSignatureVerification(p)
+		}
+		if err != nil {
+			if p != nil {
+				p.Close()
+			}
+			return nil, err
+		}
 		consumerKafkaConfig := consumerConfig.KafkaConfig
 		_ = consumerKafkaConfig.SetKey("bootstrap.servers", brokers)
 		c, err = kafka.NewConsumer(consumerKafkaConfig)
 		if err != nil {
 			return nil, err
 		}
+		chunks = newChunkAssembler(consumerConfig.ChunkTimeout)
 	}
 
 	serializer, err := NewSerializer(schemaRegistry, strategy)
@@ -58,20 +87,38 @@ func NewMessageBus(brokerList []string, schemaRegistry string, strategy SubjectS
 	}
 	var subscriptions []string
 	messageBus := &MessageBus{
-		Producer:       p,
-		Consumer:       c,
-		Handlers:       make(map[string]Handler),
-		Serializer:     serializer,
-		Subscriptions:  subscriptions,
-		stopChan:       make(chan bool),
-		rpcTimeoutMs:   5000,
-		producerConfig: producerConfig,
-		consumerConfig: consumerConfig,
+		Producer:         p,
+		Consumer:         c,
+		Handlers:         make(map[string]Handler),
+		Serializer:       serializer,
+		Subscriptions:    subscriptions,
+		topicSerializers: make(map[string]ISerializer),
+		stopChan:         make(chan bool),
+		closing:          make(chan struct{}),
+		rpcTimeoutMs:     5000,
+		producerConfig:   producerConfig,
+		consumerConfig:   consumerConfig,
+		chunks:           chunks,
+	}
+	if p != nil {
+		messageBus.deliver = deliverWith(p)
 	}
 
 	for _, opt := range opts {
 		opt(messageBus)
 	}
+	if len(messageBus.optionErrors) == 0 {
+		messageBus.optionErrors = messageBus.prepareStartupSchemas()
+	}
+	if len(messageBus.optionErrors) > 0 {
+		if p != nil {
+			p.Close()
+		}
+		if c != nil {
+			_ = c.Close()
+		}
+		return nil, errors.Join(messageBus.optionErrors...)
+	}
 	return messageBus, nil
 }
 
@@ -82,37 +129,272 @@ func WithRpcTimeoutMs(ms int) MessageBusOption {
 	}
 }
 
+// Decode values of a topic or value subject into a gogen-avro record
+// Example:
+// 		WithValueType("topic-1", func() container.AvroRecord { return schemas.NewJohnySchema() })
+func WithValueType(topicOrSubject string, newRecord func() container.AvroRecord) MessageBusOption {
+	return func(m *MessageBus) {
+		m.serializerFor(topicOrSubject).RegisterValueType(topicOrSubject, newRecord)
+	}
+}
+
+// Decode JSON Schema values of a topic or value subject into a Go type
+// Example:
+// 		WithJSONValueType("topic-1", func() interface{} { return &Order{} })
+func WithJSONValueType(topicOrSubject string, newValue func() interface{}) MessageBusOption {
+	return func(m *MessageBus) {
+		m.serializerFor(topicOrSubject).RegisterJSONValueType(topicOrSubject, newValue)
+	}
+}
+
+// Decode Protobuf values of a topic or value subject into a Go message
+// rather than a dynamic message
+// Example:
+// 		WithProtobufValueType("topic-1", func() proto.Message { return &pb.Order{} })
+func WithProtobufValueType(topicOrSubject string, newMessage func() proto.Message) MessageBusOption {
+	return func(m *MessageBus) {
+		m.serializerFor(topicOrSubject).RegisterProtobufValueType(topicOrSubject, newMessage)
+	}
+}
+
+// Decode values of a topic with a reader schema, resolving the schema
+// each message was written with into it
+// NewMessageBus returns an error if the reader schema is invalid
+func WithReaderSchema(topic string, schema string) MessageBusOption {
+	return func(m *MessageBus) {
+		err := m.serializerFor(topic).RegisterReaderSchema(topic, schema)
+		if err != nil {
+			m.optionErrors = append(m.optionErrors, err)
+		}
+	}
+}
+
+// Configure the default serializer
+// Example:
+// 		WithSerializerOptions(WithAutoRegisterSchemas(false))
+func WithSerializerOptions(opts ...SerializerOption) MessageBusOption {
+	return func(m *MessageBus) {
+		serializer, ok := m.Serializer.(*Serializer)
+		if !ok {
+			m.optionErrors = append(m.optionErrors, errors.New("serializer options only apply to the default serializer"))
+			return
+		}
+		for _, opt := range opts {
+			opt(serializer)
+		}
+	}
+}
+
+// Configure how the default serializer reaches Schema Registry
+// Example:
+// 		WithSchemaRegistryOptions(schemaregistry.WithBasicAuth("user", "secret"), schemaregistry.WithTimeout(10*time.Second))
+func WithSchemaRegistryOptions(opts ...schemaregistry.Option) MessageBusOption {
+	return func(m *MessageBus) {
+		serializer, ok := m.Serializer.(*Serializer)
+		if !ok {
+			m.optionErrors = append(m.optionErrors, errors.New("schema registry options only apply to the default serializer"))
+			return
+		}
+		client, ok := serializer.schemaRegistry.(*schemaregistry.Client)
+		if !ok {
+			m.optionErrors = append(m.optionErrors, errors.New("schema registry options only apply to the default schema registry client"))
+			return
+		}
+		for _, opt := range opts {
+			opt(client)
+		}
+	}
+}
+
+// Use another Schema Registry client with the default serializer,
+// such as one shared with other serializers or an in-memory one
+func WithSchemaRegistryClient(client ISchemaRegistryClient) MessageBusOption {
+	return func(m *MessageBus) {
+		serializer, ok := m.Serializer.(*Serializer)
+		if !ok {
+			m.optionErrors = append(m.optionErrors, errors.New("schema registry client only applies to the default serializer"))
+			return
+		}
+		serializer.schemaRegistry = client
+	}
+}
+
+// Resolve schemas from a bundle of schema files with pinned IDs
+// instead of Schema Registry, see schemaregistry.NewBundleClient
+// NewMessageBus returns an error if the bundle cannot be loaded
+// Example:
+// 		WithSchemaBundle(os.DirFS("schemas"), "schemas.lock.json")
+func WithSchemaBundle(fsys fs.FS, lockFile string) MessageBusOption {
+	return func(m *MessageBus) {
+		client, err := schemaregistry.NewBundleClient(fsys, lockFile)
+		if err != nil {
+			m.optionErrors = append(m.optionErrors, err)
+			return
+		}
+		WithSchemaRegistryClient(client)(m)
+	}
+}
+
+// Register, or look up, the schemas of records sent to a topic
+// when the bus starts, instead of at the first send
+// NewMessageBus returns an error joining every schema that is
+// incompatible or cannot be registered
+// Example:
+// 		WithStartupSchemas("topic-1", &schemas.JohnySchema{})
+func WithStartupSchemas(topic string, records ...container.AvroRecord) MessageBusOption {
+	return func(m *MessageBus) {
+		m.startupSchemas = append(m.startupSchemas, startupSchemas{topic: topic, records: records})
+	}
+}
+
+// Register, or look up, the value schemas of the .avsc files of a
+// directory when the bus starts, like WithStartupSchemas
+// Example:
+// 		WithStartupSchemaDir("topic-1", os.DirFS("schemas"), "topic-1")
+func WithStartupSchemaDir(topic string, fsys fs.FS, dir string) MessageBusOption {
+	return func(m *MessageBus) {
+		m.startupSchemas = append(m.startupSchemas, startupSchemas{topic: topic, fsys: fsys, dir: dir})
+	}
+}
+
+// Send and consume a topic with its own serializer instead of the
+// default one, such as a Serializer with other options, a
+// JSONSerializer for plain JSON or a RawSerializer for raw bytes
+// Value types and reader schemas of the topic are registered with
+// its serializer, so this option must come before them
+// WithEncryption and WithClaimCheck wrap this serializer, so this
+// option fails when it comes after them for the same topic
+// Example:
+// 		WithTopicSerializer("legacy-orders", NewJSONSerializer())
+func WithTopicSerializer(topic string, serializer ISerializer) MessageBusOption {
+	return func(m *MessageBus) {
+		if _, wrapped := m.topicSerializers[topic].(wrappingSerializer); wrapped {
+			m.optionErrors = append(m.optionErrors, fmt.Errorf("serializer of topic %s given after WithEncryption or WithClaimCheck wrapped it", topic))
+			return
+		}
+		m.topicSerializers[topic] = serializer
+	}
+}
+
+// Skip consumed messages whose MessageId was already processed by
+// the consumer group, as recorded in the store once their handler
+// returns, so that redeliveries after a rebalance do not repeat
+// side effects
+// Example:
+// 		WithDeduplication(NewMemoryDedupStore(100000, time.Hour))
+func WithDeduplication(store IDedupStore) MessageBusOption {
+	return func(m *MessageBus) {
+		m.dedupStore = store
+	}
+}
+
+// Encrypt the values of a topic with keys of the key provider,
+// wrapping the serializer of the topic. Values are encrypted before
+// WithClaimCheck stores them, whatever the order of both options
+// Example:
+// 		WithEncryption("conversations", provider)
+func WithEncryption(topic string, keyProvider IKeyProvider, opts ...EncryptionOption) MessageBusOption {
+	return func(m *MessageBus) {
+		if claimCheck, ok := m.topicSerializers[topic].(*ClaimCheckSerializer); ok {
+			claimCheck.serializer = NewEncryptingSerializer(claimCheck.serializer, keyProvider, opts...)
+			return
+		}
+		m.topicSerializers[topic] = NewEncryptingSerializer(m.serializerFor(topic), keyProvider, opts...)
+	}
+}
+
+// Store the values of a topic exceeding threshold bytes in a blob
+// store, sending a claim check instead, so that values larger than
+// the maximum message size can be sent. This wraps the serializer
+// of the topic, storing values encrypted with WithEncryption
+// Example:
+// 		WithClaimCheck("transcripts", store, 512*1024, WithBlobRetention(72*time.Hour))
+func WithClaimCheck(topic string, store IBlobStore, threshold int, opts ...ClaimCheckOption) MessageBusOption {
+	return func(m *MessageBus) {
+		claimCheck := NewClaimCheckSerializer(m.serializerFor(topic), store, threshold, opts...)
+		m.topicSerializers[topic] = claimCheck
+		m.claimChecks = append(m.claimChecks, claimCheck)
+	}
+}
+
+// CleanupClaimChecks deletes the blobs of the topics given to
+// WithClaimCheck that were stored for longer than their retention,
+// returning how many were deleted
+func (m *MessageBus) CleanupClaimChecks() (int, error) {
+	deleted := 0
+	for _, claimCheck := range m.claimChecks {
+		count, err := claimCheck.Cleanup()
+		deleted += count
+		if err != nil {
+			return deleted, err
+		}
+	}
+	return deleted, nil
+}
+
+// wrappingSerializer is implemented by the serializers wrapping the
+// serializer of a topic, such as with WithEncryption or WithClaimCheck.
+type wrappingSerializer interface {
+	unwrap() ISerializer
+}
+
+// serializerFor returns the serializer of a topic, which is the
+// default one unless another was given with WithTopicSerializer.
+func (m MessageBus) serializerFor(topic string) ISerializer {
+	if serializer, ok := m.topicSerializers[topic]; ok {
+		return serializer
+	}
+	return m.Serializer
+}
+
 // Add handler for specific topic which you will subscribe to
 func (m *MessageBus) RegisterHandler(topic string, handler Handler) {
 	m.Handlers[topic] = handler
 }
 
 // Send message to a topic
+// Values larger than the chunk size of the producer configuration
+// are sent as chunks, returning the offset of the last one
 // Returns kafka offset object and error
 // Error is nil if send operation is successful
 func (m MessageBus) Send(service string, message *ProducerRecord) (kafka.Offset, error) {
-	serializedRecord, err := m.Serializer.Serialize(service, message)
+	ctx, span := m.startSendSpan(service, message)
+	offset, err := m.send(ctx, service, message)
+	if err == nil {
+		span.SetAttributes(attribute.Int64("messaging.kafka.offset", int64(offset)))
+	}
+	endSpan(span, err)
+	return offset, err
+}
+
+func (m MessageBus) send(ctx context.Context, service string, message *ProducerRecord) (kafka.Offset, error) {
+	serializedRecord, err := m.serializerFor(service).Serialize(service, message)
+	if err != nil {
+		return -1, err
+	}
+	tracePropagator.Inject(ctx, headerCarrier{headers: &serializedRecord.Headers})
+	err = m.producerConfig.sign(service, serializedRecord)
 	if err != nil {
 		return -1, err
 	}
-	deliveryChan := make(chan kafka.Event)
-	defer close(deliveryChan)
-	err = m.Producer.Produce(&kafka.Message{
+	if chunkSize := m.producerConfig.ChunkSize; chunkSize > 0 && len(serializedRecord.Value) > chunkSize {
+		if message.Key == nil || message.Key.MessageId == "" {
+			return -1, errChunkWithoutKey
+		}
+		return m.sendChunks(service, message.Key.MessageId, serializedRecord)
+	}
+	delivered, err := m.produce(&kafka.Message{
 		TopicPartition: kafka.TopicPartition{
 			Topic:     &service,
 			Partition: kafka.PartitionAny,
 		},
 		Value:         serializedRecord.Value,
 		Key:           serializedRecord.Key,
+		Headers:       serializedRecord.Headers,
 		Timestamp:     time.Now(),
 		TimestampType: kafka.TimestampCreateTime,
-	}, deliveryChan)
-	if err != nil {
-		return -1, err
-	}
-	e := <-deliveryChan
-	ev := e.(*kafka.Message)
-	return ev.TopicPartition.Offset, ev.TopicPartition.Error
+	})
+	return delivered.Offset, err
 }
 
 func (m *MessageBus) pollAndHandleMessage(handler Handler) {
@@ -120,6 +402,11 @@ func (m *MessageBus) pollAndHandleMessage(handler Handler) {
 		select {
 		case <-m.stopChan:
 			return
+		case <-m.closing:
+			// Committing another message would pass those that could
+			// not be dead-lettered, which are consumed again on restart
+			<-m.stopChan
+			return
 		default:
 			ev := m.Consumer.Poll(m.consumerConfig.PollIntervalMs)
 			if ev == nil {
@@ -127,15 +414,7 @@ func (m *MessageBus) pollAndHandleMessage(handler Handler) {
 			}
 			switch e := ev.(type) {
 			case *kafka.Message:
-				record, err := m.Serializer.Deserialize(e)
-				if err != nil {
-					_, _ = fmt.Fprintln(os.Stderr, err)
-				}
-				handler.HandleMessage(MessageContext{
-					Incoming: record,
-					Sender:   m,
-				})
-				_, _ = m.Consumer.CommitMessage(e)
+				m.handleMessage(handler, e)
 			case kafka.Error:
 				_, _ = fmt.Fprintf(os.Stderr, "Error %v: %v\n", e.Code(), e)
 			}
@@ -143,6 +422,76 @@ func (m *MessageBus) pollAndHandleMessage(handler Handler) {
 	}
 }
 
+// handleMessage reassembles, verifies, deserializes and handles a
+// consumed message, committing it unless the bus disconnected before
+// it could be dead-lettered.
+func (m *MessageBus) handleMessage(handler Handler, message *kafka.Message) {
+	if !m.discardChunks(m.chunks.expire()) {
+		return
+	}
+	e, chunks, err := m.chunks.add(message)
+	if err != nil {
+		// Commits never pass the chunks of other messages still being
+		// reassembled
+		m.handleFailed(message, []*kafka.Message{message}, err)
+		return
+	}
+	if e == nil {
+		// Chunks are committed along with the last chunk of their message
+		return
+	}
+	var origin string
+	if m.consumerConfig.SignatureVerifier != nil {
+		origin, err = m.consumerConfig.verifySignature(e)
+		if err != nil {
+			m.reject(e, chunks, err)
+			return
+		}
+	}
+	record, err := m.serializerFor(*e.TopicPartition.Topic).Deserialize(e)
+	if err != nil {
+		m.handleFailed(e, chunks, err)
+		return
+	}
+	if m.consumerConfig.SignatureVerifier != nil && (record.Key == nil || record.Key.OriginService != origin) {
+		m.reject(e, chunks, fmt.Errorf("message is signed with a key of %s but claims another origin service", origin))
+		return
+	}
+	if handle, err := m.handleExpired(chunks, record); !handle {
+		// Messages that could not be dead-lettered before disconnecting
+		// are left uncommitted
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+		} else {
+			m.commit(e)
+		}
+		return
+	}
+	if m.isDuplicate(record) {
+		m.commit(e)
+		return
+	}
+	ctx, span := m.startProcessSpan(e, record)
+	messageContext := MessageContext{
+		Incoming: record,
+		Sender:   m,
+		Context:  ctx,
+	}
+	var handleErr error
+	if failing, ok := handler.(failingHandler); ok {
+		handleErr = failing.handleMessage(messageContext)
+	} else {
+		handler.HandleMessage(messageContext)
+	}
+	endSpan(span, handleErr)
+	if handleErr != nil {
+		m.handleFailed(e, chunks, handleErr)
+		return
+	}
+	m.markProcessed(record)
+	m.commit(e)
+}
+
 // Subscribe to a topic
 // Message will be passed to the handler that you have registered
 func (m *MessageBus) Subscribe(service string) error {
@@ -193,6 +542,7 @@ func (m *MessageBus) Disconnect() error {
 		m.Producer.Close()
 	}
 	if m.Consumer != nil {
+		close(m.closing)
 		m.stopChan <- true
 		err := m.Consumer.Close()
 		if err != nil {
@@ -214,7 +564,17 @@ func (m *MessageBus) Request(service string, message *ProducerRecord) (*Consumer
 	if replyTopic == "" {
 		return nil, errors.New("message should have reply topic")
 	}
+	ctx, span := m.startRequestSpan(service, message)
+	tracePropagator.Inject(ctx, keyCarrier{key: message.Key})
+	result, err := m.request(service, replyTopic, message)
+	if result != nil {
+		span.AddLink(trace.Link{SpanContext: replySpanContext(result)})
+	}
+	endSpan(span, err)
+	return result, err
+}
 
+func (m *MessageBus) request(service string, replyTopic string, message *ProducerRecord) (*ConsumerRecord, error) {
 	resultChan := make(chan *ConsumerRecord)
 	defer close(resultChan)
 
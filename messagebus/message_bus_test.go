@@ -0,0 +1,96 @@
+package messagebus
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/kata-ai/messagebus-golang-kafka/messagebus/blobstore"
+)
+
+func newOptionsBus(opts ...MessageBusOption) *MessageBus {
+	m := &MessageBus{Serializer: NewJSONSerializer(), topicSerializers: make(map[string]ISerializer)}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+func TestEncryptionBeneathClaimCheck(t *testing.T) {
+	provider, err := NewStaticKeyProvider("2024-01", map[string][]byte{"2024-01": bytes.Repeat([]byte{1}, 32)})
+	if err != nil {
+		t.Fatalf("cannot create key provider: %v", err)
+	}
+	store, err := blobstore.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("cannot create blob store: %v", err)
+	}
+	tests := []struct {
+		name string
+		opts []MessageBusOption
+	}{
+		{"encryption first", []MessageBusOption{WithEncryption("transcripts", provider), WithClaimCheck("transcripts", store, 8)}},
+		{"claim check first", []MessageBusOption{WithClaimCheck("transcripts", store, 8), WithEncryption("transcripts", provider)}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m := newOptionsBus(test.opts...)
+			key, err := NewMessageKey("transcriber")
+			if err != nil {
+				t.Fatalf("cannot create message key: %v", err)
+			}
+			serialized, err := m.serializerFor("transcripts").Serialize("transcripts", NewProducerRecord(key, "a transcript in plaintext"))
+			if err != nil {
+				t.Fatalf("cannot serialize value: %v", err)
+			}
+			var blobId string
+			for _, header := range serialized.Headers {
+				if header.Key == claimCheckHeader {
+					blobId = string(header.Value)
+				}
+			}
+			blob, err := store.Get(blobId)
+			if err != nil {
+				t.Fatalf("cannot get blob: %v", err)
+			}
+			if bytes.Contains(blob, []byte("plaintext")) {
+				t.Errorf("blob is stored in plaintext: %s", blob)
+			}
+		})
+	}
+}
+
+func TestTopicSerializerAfterWrapping(t *testing.T) {
+	provider, err := NewStaticKeyProvider("2024-01", map[string][]byte{"2024-01": bytes.Repeat([]byte{1}, 32)})
+	if err != nil {
+		t.Fatalf("cannot create key provider: %v", err)
+	}
+	m := newOptionsBus(
+		WithEncryption("conversations", provider),
+		WithTopicSerializer("conversations", NewJSONSerializer()),
+	)
+	if len(m.optionErrors) != 1 {
+		t.Errorf("option errors are %v, want one", m.optionErrors)
+	}
+	if _, ok := m.serializerFor("conversations").(*EncryptingSerializer); !ok {
+		t.Error("encryption was replaced")
+	}
+}
+
+func TestCleanupWrappedClaimChecks(t *testing.T) {
+	store, err := blobstore.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("cannot create blob store: %v", err)
+	}
+	if err := store.Put("expired", []byte("value")); err != nil {
+		t.Fatalf("cannot store blob: %v", err)
+	}
+	m := newOptionsBus(
+		WithClaimCheck("transcripts", store, 8, WithBlobRetention(-time.Hour)),
+		WithClaimCheck("transcripts", store, 1024),
+	)
+	deleted, err := m.CleanupClaimChecks()
+	if err != nil || deleted != 1 {
+		t.Errorf("deleted %d blobs, %v, want 1", deleted, err)
+	}
+}
@@ -35,9 +35,21 @@ type MessageKey struct {
 	MessageBusVersion string `json:"messageBusVersion"`
 
 	Timestamp int64 `json:"timestamp"`
+
+	TenantId string `json:"tenantId"`
+
+	TraceParent string `json:"traceParent"`
+
+	TraceState string `json:"traceState"`
+
+	Priority int32 `json:"priority"`
+
+	ExpiresAt int64 `json:"expiresAt"`
+
+	Attributes map[string]string `json:"attributes"`
 }
 
-const MessageKeyAvroCRC64Fingerprint = "k\xe9\xb8G\xb5\x9a=v"
+const MessageKeyAvroCRC64Fingerprint = "\x9cG\x05\xd8\xda\r\xe4'"
 
 func NewMessageKey(originService string, options ...MessageKeyOption) (*MessageKey, error) {
 	id := uuid.New().String()
@@ -84,6 +96,56 @@ func WithReplyTopic(topic string) MessageKeyOption {
 	}
 }
 
+// Set the tenant the message belongs to
+func WithTenantId(tenantId string) MessageKeyOption {
+	return func(k *MessageKey) {
+		k.TenantId = tenantId
+	}
+}
+
+// Set the W3C trace context the message is part of, as the values
+// of the traceparent and tracestate headers
+func WithTraceContext(traceParent string, traceState string) MessageKeyOption {
+	return func(k *MessageKey) {
+		k.TraceParent = traceParent
+		k.TraceState = traceState
+	}
+}
+
+// Set the priority of the message, higher values being more urgent
+func WithPriority(priority int32) MessageKeyOption {
+	return func(k *MessageKey) {
+		k.Priority = priority
+	}
+}
+
+// Set the message to expire once the duration has elapsed since
+// its timestamp
+func WithTTL(ttl time.Duration) MessageKeyOption {
+	return func(k *MessageKey) {
+		k.ExpiresAt = k.Timestamp + ttl.Milliseconds()
+	}
+}
+
+// Set the time at which the message expires
+func WithExpiresAt(expiresAt time.Time) MessageKeyOption {
+	return func(k *MessageKey) {
+		k.ExpiresAt = expiresAt.UnixNano() / int64(time.Millisecond)
+	}
+}
+
+// Add a custom attribute to the message
+// Example:
+// 		NewMessageKey("service-1", WithAttribute("region", "eu-west-1"))
+func WithAttribute(name string, value string) MessageKeyOption {
+	return func(k *MessageKey) {
+		if k.Attributes == nil {
+			k.Attributes = make(map[string]string)
+		}
+		k.Attributes[name] = value
+	}
+}
+
 func DeserializeMessageKeyFromSchema(r io.Reader, schema string) (*MessageKey, error) {
 	t := &MessageKey{}
 
@@ -137,6 +199,30 @@ func writeMessageKey(r *MessageKey, w io.Writer) error {
 	if err != nil {
 		return err
 	}
+	err = vm.WriteString(r.TenantId, w)
+	if err != nil {
+		return err
+	}
+	err = vm.WriteString(r.TraceParent, w)
+	if err != nil {
+		return err
+	}
+	err = vm.WriteString(r.TraceState, w)
+	if err != nil {
+		return err
+	}
+	err = vm.WriteInt(r.Priority, w)
+	if err != nil {
+		return err
+	}
+	err = vm.WriteLong(r.ExpiresAt, w)
+	if err != nil {
+		return err
+	}
+	err = writeMapString(r.Attributes, w)
+	if err != nil {
+		return err
+	}
 	return err
 }
 
@@ -145,7 +231,7 @@ func (r *MessageKey) Serialize(w io.Writer) error {
 }
 
 func (r *MessageKey) Schema() string {
-	return "{\"fields\":[{\"name\":\"valueSubject\",\"type\":\"string\"},{\"name\":\"messageId\",\"type\":\"string\"},{\"name\":\"correlationId\",\"type\":\"string\"},{\"name\":\"conversationId\",\"type\":\"string\"},{\"default\":\"\",\"name\":\"replyTopic\",\"type\":\"string\"},{\"name\":\"originService\",\"type\":\"string\"},{\"name\":\"originHostname\",\"type\":\"string\"},{\"name\":\"messageBusVersion\",\"type\":\"string\"},{\"name\":\"timestamp\",\"type\":\"long\"}],\"name\":\"ai.kata.kafka.MessageKey\",\"type\":\"record\"}"
+	return "{\"fields\":[{\"name\":\"valueSubject\",\"type\":\"string\"},{\"name\":\"messageId\",\"type\":\"string\"},{\"name\":\"correlationId\",\"type\":\"string\"},{\"name\":\"conversationId\",\"type\":\"string\"},{\"default\":\"\",\"name\":\"replyTopic\",\"type\":\"string\"},{\"name\":\"originService\",\"type\":\"string\"},{\"name\":\"originHostname\",\"type\":\"string\"},{\"name\":\"messageBusVersion\",\"type\":\"string\"},{\"name\":\"timestamp\",\"type\":\"long\"},{\"default\":\"\",\"name\":\"tenantId\",\"type\":\"string\"},{\"default\":\"\",\"name\":\"traceParent\",\"type\":\"string\"},{\"default\":\"\",\"name\":\"traceState\",\"type\":\"string\"},{\"default\":0,\"name\":\"priority\",\"type\":\"int\"},{\"default\":0,\"name\":\"expiresAt\",\"type\":\"long\"},{\"default\":{},\"name\":\"attributes\",\"type\":{\"type\":\"map\",\"values\":\"string\"}}],\"name\":\"ai.kata.kafka.MessageKey\",\"type\":\"record\"}"
 }
 
 func (r *MessageKey) SchemaName() string {
@@ -181,6 +267,19 @@ func (r *MessageKey) Get(i int) types.Field {
 		return &types.String{Target: &r.MessageBusVersion}
 	case 8:
 		return &types.Long{Target: &r.Timestamp}
+	case 9:
+		return &types.String{Target: &r.TenantId}
+	case 10:
+		return &types.String{Target: &r.TraceParent}
+	case 11:
+		return &types.String{Target: &r.TraceState}
+	case 12:
+		return &types.Int{Target: &r.Priority}
+	case 13:
+		return &types.Long{Target: &r.ExpiresAt}
+	case 14:
+		r.Attributes = make(map[string]string)
+		return &mapStringField{target: r.Attributes}
 	}
 	panic("Unknown field index")
 }
@@ -190,6 +289,24 @@ func (r *MessageKey) SetDefault(i int) {
 	case 4:
 		r.ReplyTopic = ""
 		return
+	case 9:
+		r.TenantId = ""
+		return
+	case 10:
+		r.TraceParent = ""
+		return
+	case 11:
+		r.TraceState = ""
+		return
+	case 12:
+		r.Priority = 0
+		return
+	case 13:
+		r.ExpiresAt = 0
+		return
+	case 14:
+		r.Attributes = make(map[string]string)
+		return
 	}
 	panic("Unknown field index")
 }
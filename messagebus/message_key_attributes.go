@@ -0,0 +1,69 @@
+package messagebus
+
+import (
+	"io"
+
+	"github.com/actgardner/gogen-avro/v7/vm"
+	"github.com/actgardner/gogen-avro/v7/vm/types"
+)
+
+// The attributes of MessageKey are a map of strings, which the
+// generated code writes and reads with the functions of this file.
+
+func writeMapString(r map[string]string, w io.Writer) error {
+	err := vm.WriteLong(int64(len(r)), w)
+	if err != nil || len(r) == 0 {
+		return err
+	}
+	for k, e := range r {
+		err = vm.WriteString(k, w)
+		if err != nil {
+			return err
+		}
+		err = vm.WriteString(e, w)
+		if err != nil {
+			return err
+		}
+	}
+	return vm.WriteLong(0, w)
+}
+
+// mapStringField reads the entries of a map of strings, which are
+// added to the target map once they are all read.
+type mapStringField struct {
+	target map[string]string
+	keys   []string
+	values []*string
+}
+
+func (_ *mapStringField) SetBoolean(v bool)    { panic("Unsupported operation") }
+func (_ *mapStringField) SetInt(v int32)       { panic("Unsupported operation") }
+func (_ *mapStringField) SetLong(v int64)      { panic("Unsupported operation") }
+func (_ *mapStringField) SetFloat(v float32)   { panic("Unsupported operation") }
+func (_ *mapStringField) SetDouble(v float64)  { panic("Unsupported operation") }
+func (_ *mapStringField) SetBytes(v []byte)    { panic("Unsupported operation") }
+func (_ *mapStringField) SetString(v string)   { panic("Unsupported operation") }
+func (_ *mapStringField) SetUnionElem(v int64) { panic("Unsupported operation") }
+func (_ *mapStringField) Get(i int) types.Field {
+	panic("Unsupported operation")
+}
+func (_ *mapStringField) SetDefault(i int) { panic("Unsupported operation") }
+func (_ *mapStringField) NullField(i int)  { panic("Unsupported operation") }
+func (_ *mapStringField) AppendArray() types.Field {
+	panic("Unsupported operation")
+}
+
+func (r *mapStringField) AppendMap(key string) types.Field {
+	var value string
+	r.keys = append(r.keys, key)
+	r.values = append(r.values, &value)
+	return &types.String{Target: &value}
+}
+
+func (r *mapStringField) Finalize() {
+	for i := range r.keys {
+		r.target[r.keys[i]] = *r.values[i]
+	}
+	r.keys = nil
+	r.values = nil
+}
@@ -0,0 +1,311 @@
+// Package outbox publishes messages with the transactional outbox
+// pattern: records are inserted into an outbox table within the
+// transaction of the changes they announce, and a relay publishes
+// them through a message bus once the transaction is committed.
+package outbox
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+
+	"github.com/actgardner/gogen-avro/v7/compiler"
+	"github.com/actgardner/gogen-avro/v7/container"
+	"github.com/actgardner/gogen-avro/v7/vm"
+	"github.com/kata-ai/messagebus-golang-kafka/messagebus"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Dialect is the SQL dialect of the outbox database, shared with
+// the SQL dedup store of the message bus.
+type Dialect = messagebus.SQLDialect
+
+const (
+	SQLITE   = messagebus.SQLITE
+	POSTGRES = messagebus.POSTGRES
+	MYSQL    = messagebus.MYSQL
+)
+
+// Formats values are stored in the outbox table with.
+const (
+	avroFormat     = "avro"
+	protobufFormat = "protobuf"
+	jsonFormat     = "json"
+	bytesFormat    = "bytes"
+	stringFormat   = "string"
+)
+
+// ErrMissingKey is returned when inserting a record without a
+// message key, which sending requires.
+var ErrMissingKey = errors.New("outbox records need a message key")
+
+// Outbox inserts records into an outbox table, and reads them back
+// for the relay. Values that are neither []byte nor string must have
+// their type registered with WithValueType, so that the relay can
+// rebuild them before they are serialized.
+type Outbox struct {
+	db           *sql.DB
+	dialect      Dialect
+	table        string
+	valueTypes   map[string]func() interface{}
+	programs     map[string]*vm.Program
+	programsLock sync.RWMutex
+}
+
+type Option func(o *Outbox)
+
+type pendingRecord struct {
+	id     int64
+	topic  string
+	record *messagebus.ProducerRecord
+	// err is why the record cannot be rebuilt, such as a value type
+	// that is not registered
+	err error
+}
+
+// New creates an outbox over db, creating its table when it does
+// not exist yet. The table is named messagebus_outbox unless
+// configured otherwise.
+// Example:
+// 		box, err := outbox.New(db, outbox.POSTGRES, outbox.WithValueType(func() interface{} { return &schemas.OrderCreated{} }))
+func New(db *sql.DB, dialect Dialect, opts ...Option) (*Outbox, error) {
+	o := &Outbox{
+		db:         db,
+		dialect:    dialect,
+		table:      "messagebus_outbox",
+		valueTypes: make(map[string]func() interface{}),
+		programs:   make(map[string]*vm.Program),
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	var id, blob string
+	switch dialect {
+	case SQLITE:
+		id, blob = "INTEGER PRIMARY KEY AUTOINCREMENT", "BLOB"
+	case POSTGRES:
+		id, blob = "BIGSERIAL PRIMARY KEY", "BYTEA"
+	case MYSQL:
+		id, blob = "BIGINT AUTO_INCREMENT PRIMARY KEY", "LONGBLOB"
+	default:
+		return nil, fmt.Errorf("unknown SQL dialect %s", dialect)
+	}
+	_, err := db.Exec("CREATE TABLE IF NOT EXISTS " + o.table + " (" +
+		"id " + id + ", " +
+		"topic VARCHAR(255) NOT NULL, " +
+		"message_key TEXT NOT NULL, " +
+		"record_key " + blob + ", " +
+		"value_type VARCHAR(255) NOT NULL, " +
+		"value_format VARCHAR(16) NOT NULL, " +
+		"value " + blob + ", " +
+		"sent INTEGER NOT NULL DEFAULT 0)")
+	if err != nil {
+		return nil, fmt.Errorf("cannot create outbox table %s: %v", o.table, err)
+	}
+	return o, nil
+}
+
+// Configure the name of the outbox table
+func WithTable(table string) Option {
+	return func(o *Outbox) {
+		o.table = table
+	}
+}
+
+// Register the type of values inserted into the outbox, which is
+// a gogen-avro record, a Go message or any Go type sent with JSON
+// Schema, so that the relay can rebuild them
+// Example:
+// 		WithValueType(func() interface{} { return &schemas.OrderCreated{} })
+func WithValueType(newValue func() interface{}) Option {
+	return func(o *Outbox) {
+		o.valueTypes[typeName(newValue())] = newValue
+	}
+}
+
+// Insert adds a record to be sent to a topic within the transaction,
+// so that it is only sent if the transaction commits.
+func (o *Outbox) Insert(tx *sql.Tx, topic string, record *messagebus.ProducerRecord) error {
+	if record.Key == nil {
+		return ErrMissingKey
+	}
+	key, err := json.Marshal(record.Key)
+	if err != nil {
+		return err
+	}
+	valueType, format, value, err := o.encodeValue(record.Value)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("INSERT INTO "+o.table+" (topic, message_key, record_key, value_type, value_format, value) VALUES ("+
+		o.placeholders(6)+")", topic, string(key), record.RecordKey, valueType, format, value)
+	return err
+}
+
+func (o *Outbox) encodeValue(value interface{}) (string, string, []byte, error) {
+	switch v := value.(type) {
+	case []byte:
+		return "", bytesFormat, v, nil
+	case string:
+		return "", stringFormat, []byte(v), nil
+	}
+	name := typeName(value)
+	if _, ok := o.valueTypes[name]; !ok {
+		return "", "", nil, fmt.Errorf("value type %s is not registered with the outbox", name)
+	}
+	switch v := value.(type) {
+	case proto.Message:
+		encoded, err := protojson.Marshal(v)
+		return name, protobufFormat, encoded, err
+	case container.AvroRecord:
+		var buf bytes.Buffer
+		err := v.Serialize(&buf)
+		return name, avroFormat, buf.Bytes(), err
+	}
+	encoded, err := json.Marshal(value)
+	return name, jsonFormat, encoded, err
+}
+
+// pending reads the records that are not sent yet, in the order
+// they were inserted.
+func (o *Outbox) pending(limit int) ([]pendingRecord, error) {
+	rows, err := o.db.Query("SELECT id, topic, message_key, record_key, value_type, value_format, value FROM "+o.table+
+		" WHERE sent = 0 ORDER BY id LIMIT "+o.placeholder(1), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var records []pendingRecord
+	for rows.Next() {
+		var pending pendingRecord
+		var key, valueType, format string
+		var recordKey, value []byte
+		err = rows.Scan(&pending.id, &pending.topic, &key, &recordKey, &valueType, &format, &value)
+		if err != nil {
+			return nil, err
+		}
+		record := &messagebus.ProducerRecord{Key: &messagebus.MessageKey{}, RecordKey: recordKey}
+		err = json.Unmarshal([]byte(key), record.Key)
+		if err != nil {
+			pending.err = fmt.Errorf("invalid message key of outbox record %d: %v", pending.id, err)
+		} else if record.Value, err = o.decodeValue(valueType, format, value); err != nil {
+			pending.err = fmt.Errorf("invalid value of outbox record %d: %v", pending.id, err)
+		}
+		pending.record = record
+		records = append(records, pending)
+	}
+	return records, rows.Err()
+}
+
+func (o *Outbox) decodeValue(valueType string, format string, value []byte) (interface{}, error) {
+	switch format {
+	case bytesFormat:
+		return value, nil
+	case stringFormat:
+		return string(value), nil
+	}
+	newValue, ok := o.valueTypes[valueType]
+	if !ok {
+		return nil, fmt.Errorf("value type %s is not registered with the outbox", valueType)
+	}
+	decoded := newValue()
+	switch format {
+	case protobufFormat:
+		return decoded, protojson.Unmarshal(value, decoded.(proto.Message))
+	case avroFormat:
+		return decoded, o.decodeAvro(value, decoded.(container.AvroRecord))
+	case jsonFormat:
+		return decoded, json.Unmarshal(value, decoded)
+	}
+	return nil, fmt.Errorf("unknown value format %s", format)
+}
+
+func (o *Outbox) decodeAvro(value []byte, record container.AvroRecord) error {
+	schema := record.Schema()
+	o.programsLock.RLock()
+	program := o.programs[schema]
+	o.programsLock.RUnlock()
+	if program == nil {
+		var err error
+		program, err = compiler.CompileSchemaBytes([]byte(schema), []byte(schema))
+		if err != nil {
+			return err
+		}
+		o.programsLock.Lock()
+		o.programs[schema] = program
+		o.programsLock.Unlock()
+	}
+	return vm.Eval(bytes.NewReader(value), program, record)
+}
+
+// markSent records that a record was sent, so that it is not
+// relayed again.
+func (o *Outbox) markSent(id int64) error {
+	_, err := o.db.Exec("UPDATE "+o.table+" SET sent = 1 WHERE id = "+o.placeholder(1), id)
+	return err
+}
+
+// markFailed records that a record cannot be rebuilt, so that it
+// does not hold back the records after it.
+func (o *Outbox) markFailed(id int64) error {
+	_, err := o.db.Exec("UPDATE "+o.table+" SET sent = 2 WHERE id = "+o.placeholder(1), id)
+	return err
+}
+
+// RetryFailed makes the records that could not be rebuilt pending
+// again, such as after registering their value type, returning how
+// many there were.
+func (o *Outbox) RetryFailed() (int64, error) {
+	result, err := o.db.Exec("UPDATE " + o.table + " SET sent = 0 WHERE sent = 2")
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// Purge deletes the records that were sent, returning how many were
+// deleted, to bound the table size.
+func (o *Outbox) Purge() (int64, error) {
+	result, err := o.db.Exec("DELETE FROM " + o.table + " WHERE sent = 1")
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (o *Outbox) placeholder(n int) string {
+	if o.dialect == POSTGRES {
+		return "$" + strconv.Itoa(n)
+	}
+	return "?"
+}
+
+func (o *Outbox) placeholders(count int) string {
+	var buf bytes.Buffer
+	for n := 1; n <= count; n++ {
+		if n > 1 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(o.placeholder(n))
+	}
+	return buf.String()
+}
+
+// typeName names the type of a value regardless of whether it is
+// a pointer, as values are always rebuilt as pointers.
+func typeName(value interface{}) string {
+	t := reflect.TypeOf(value)
+	if t == nil {
+		return ""
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.String()
+}
@@ -0,0 +1,268 @@
+package outbox
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/kata-ai/messagebus-golang-kafka/messagebus"
+	_ "github.com/mattn/go-sqlite3"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type orderCreated struct {
+	OrderId string `json:"orderId"`
+	Total   int64  `json:"total"`
+}
+
+// fakeBus records the messages sent through it, failing once it
+// has sent failAfter messages when failAfter is positive.
+type fakeBus struct {
+	topics    []string
+	records   []*messagebus.ProducerRecord
+	failAfter int
+}
+
+func (b *fakeBus) Send(service string, message *messagebus.ProducerRecord) (kafka.Offset, error) {
+	if b.failAfter > 0 && len(b.records) >= b.failAfter {
+		return -1, errors.New("broker unavailable")
+	}
+	b.topics = append(b.topics, service)
+	b.records = append(b.records, message)
+	return kafka.Offset(len(b.records) - 1), nil
+}
+
+func (b *fakeBus) Subscribe(topic string) error {
+	return nil
+}
+
+func (b *fakeBus) Unsubscribe(topic string) error {
+	return nil
+}
+
+func (b *fakeBus) Request(service string, message *messagebus.ProducerRecord) (*messagebus.ConsumerRecord, error) {
+	return nil, errors.New("not supported")
+}
+
+func (b *fakeBus) Disconnect() error {
+	return nil
+}
+
+func newTestOutbox(t *testing.T, opts ...Option) (*sql.DB, *Outbox) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("cannot open database: %v", err)
+	}
+	// Every connection to :memory: opens another database
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = db.Close() })
+	box, err := New(db, SQLITE, opts...)
+	if err != nil {
+		t.Fatalf("cannot create outbox: %v", err)
+	}
+	return db, box
+}
+
+func insert(t *testing.T, db *sql.DB, box *Outbox, topic string, records ...*messagebus.ProducerRecord) {
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("cannot begin transaction: %v", err)
+	}
+	for _, record := range records {
+		if err := box.Insert(tx, topic, record); err != nil {
+			t.Fatalf("cannot insert record: %v", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("cannot commit transaction: %v", err)
+	}
+}
+
+func TestRelayValueFormats(t *testing.T) {
+	db, box := newTestOutbox(t,
+		WithValueType(func() interface{} { return &orderCreated{} }),
+		WithValueType(func() interface{} { return &wrapperspb.StringValue{} }),
+		WithValueType(func() interface{} { return &messagebus.MessageKey{} }),
+	)
+	key, err := messagebus.NewMessageKey("order-service",
+		messagebus.WithTenantId("tenant-1"),
+		messagebus.WithTraceContext("00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01", "vendor=value"),
+		messagebus.WithPriority(5),
+		messagebus.WithAttribute("region", "id"),
+	)
+	if err != nil {
+		t.Fatalf("cannot create message key: %v", err)
+	}
+	avroValue, err := messagebus.NewMessageKey("billing-service", messagebus.WithAttribute("currency", "IDR"))
+	if err != nil {
+		t.Fatalf("cannot create message key: %v", err)
+	}
+	values := []struct {
+		format string
+		value  interface{}
+	}{
+		{bytesFormat, []byte{0, 1, 2}},
+		{stringFormat, "order created"},
+		{jsonFormat, &orderCreated{OrderId: "order-1", Total: 15000}},
+		{protobufFormat, wrapperspb.String("order-1")},
+		{avroFormat, avroValue},
+	}
+	for _, value := range values {
+		record := messagebus.NewProducerRecord(key, value.value)
+		record.RecordKey = []byte(value.format)
+		insert(t, db, box, "orders", record)
+	}
+
+	bus := &fakeBus{}
+	relay, err := NewRelay(box, bus, WithBatchSize(2))
+	if err != nil {
+		t.Fatalf("cannot create relay: %v", err)
+	}
+	sent, err := relay.RelayPending()
+	if err != nil || sent != len(values) {
+		t.Fatalf("relayed %d records, %v, want %d", sent, err, len(values))
+	}
+	for i, value := range values {
+		t.Run(value.format, func(t *testing.T) {
+			if bus.topics[i] != "orders" || string(bus.records[i].RecordKey) != value.format {
+				t.Errorf("sent record %s to %s", bus.records[i].RecordKey, bus.topics[i])
+			}
+			if !reflect.DeepEqual(bus.records[i].Key, key) {
+				t.Errorf("message key is %+v, want %+v", bus.records[i].Key, key)
+			}
+			got := bus.records[i].Value
+			var equal bool
+			switch want := value.value.(type) {
+			case proto.Message:
+				message, ok := got.(proto.Message)
+				equal = ok && proto.Equal(message, want)
+			case []byte:
+				gotBytes, ok := got.([]byte)
+				equal = ok && bytes.Equal(gotBytes, want)
+			default:
+				equal = reflect.DeepEqual(got, want)
+			}
+			if !equal {
+				t.Errorf("value is %#v, want %#v", got, value.value)
+			}
+		})
+	}
+
+	sent, err = relay.RelayPending()
+	if err != nil || sent != 0 {
+		t.Errorf("relayed %d records again, %v", sent, err)
+	}
+	purged, err := box.Purge()
+	if err != nil || purged != int64(len(values)) {
+		t.Errorf("purged %d records, %v", purged, err)
+	}
+}
+
+func TestRelayFailure(t *testing.T) {
+	db, box := newTestOutbox(t)
+	key, err := messagebus.NewMessageKey("order-service")
+	if err != nil {
+		t.Fatalf("cannot create message key: %v", err)
+	}
+	insert(t, db, box, "orders",
+		messagebus.NewProducerRecord(key, "first"),
+		messagebus.NewProducerRecord(key, "second"),
+	)
+
+	bus := &fakeBus{failAfter: 1}
+	relay, err := NewRelay(box, bus)
+	if err != nil {
+		t.Fatalf("cannot create relay: %v", err)
+	}
+	sent, err := relay.RelayPending()
+	if err == nil || sent != 1 {
+		t.Fatalf("relayed %d records, %v, want 1 and an error", sent, err)
+	}
+	bus.failAfter = 0
+	sent, err = relay.RelayPending()
+	if err != nil || sent != 1 || bus.records[1].Value != "second" {
+		t.Errorf("relayed %d records, %v, after the failure", sent, err)
+	}
+}
+
+func TestRelayUnregisteredValueType(t *testing.T) {
+	db, box := newTestOutbox(t, WithValueType(func() interface{} { return &orderCreated{} }))
+	key, err := messagebus.NewMessageKey("order-service")
+	if err != nil {
+		t.Fatalf("cannot create message key: %v", err)
+	}
+	insert(t, db, box, "orders",
+		messagebus.NewProducerRecord(key, &orderCreated{OrderId: "order-1"}),
+		messagebus.NewProducerRecord(key, "after"),
+	)
+
+	// The relay does not know the type the record was inserted with
+	unregistered, err := New(db, SQLITE)
+	if err != nil {
+		t.Fatalf("cannot create outbox: %v", err)
+	}
+	bus := &fakeBus{}
+	relay, err := NewRelay(unregistered, bus)
+	if err != nil {
+		t.Fatalf("cannot create relay: %v", err)
+	}
+	sent, err := relay.RelayPending()
+	if err == nil || sent != 0 {
+		t.Fatalf("relayed %d records, %v, want none and an error", sent, err)
+	}
+
+	relay, err = NewRelay(unregistered, bus, WithSkipInvalidRecords())
+	if err != nil {
+		t.Fatalf("cannot create relay: %v", err)
+	}
+	sent, err = relay.RelayPending()
+	if err != nil || sent != 1 || bus.records[0].Value != "after" {
+		t.Fatalf("relayed %d records, %v, want the record after the failed one", sent, err)
+	}
+
+	failed, err := box.RetryFailed()
+	if err != nil || failed != 1 {
+		t.Fatalf("retried %d failed records, %v", failed, err)
+	}
+	relay, err = NewRelay(box, bus)
+	if err != nil {
+		t.Fatalf("cannot create relay: %v", err)
+	}
+	sent, err = relay.RelayPending()
+	if err != nil || sent != 1 || !reflect.DeepEqual(bus.records[1].Value, &orderCreated{OrderId: "order-1"}) {
+		t.Errorf("relayed %d records, %v, after retrying", sent, err)
+	}
+}
+
+func TestRelayOptions(t *testing.T) {
+	_, box := newTestOutbox(t)
+	if _, err := NewRelay(box, &fakeBus{}, WithBatchSize(0)); err == nil {
+		t.Error("created relay with an empty batch size")
+	}
+	if _, err := NewRelay(box, &fakeBus{}, WithPollInterval(0)); err == nil {
+		t.Error("created relay without poll interval")
+	}
+	relay, err := NewRelay(box, &fakeBus{})
+	if err != nil {
+		t.Fatalf("cannot create relay: %v", err)
+	}
+	// Stop returns even if the relay was never started
+	relay.Stop()
+	relay.Stop()
+}
+
+func TestInsertWithoutKey(t *testing.T) {
+	db, box := newTestOutbox(t)
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("cannot begin transaction: %v", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+	if err := box.Insert(tx, "orders", messagebus.NewProducerRecord(nil, "value")); err != ErrMissingKey {
+		t.Errorf("inserted record without key: %v", err)
+	}
+}
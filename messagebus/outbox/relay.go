@@ -0,0 +1,144 @@
+package outbox
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/kata-ai/messagebus-golang-kafka/messagebus"
+)
+
+// Relay publishes the records of an outbox through a message bus,
+// which serializes them the way Send always does. Records are sent
+// in the order they were inserted, and a record that fails to send or
+// cannot be rebuilt, such as one of a value type that is not
+// registered, holds back the ones after it until it is sent. With
+// WithSkipInvalidRecords, records that cannot be rebuilt are reported
+// and marked failed instead, until RetryFailed makes them pending
+// again. A record may be sent twice if the process dies between
+// sending it and marking it sent, so consumers should deduplicate by
+// MessageId. Only one relay should run per outbox table.
+type Relay struct {
+	outbox       *Outbox
+	bus          messagebus.IMessageBus
+	batchSize    int
+	pollInterval time.Duration
+	skipInvalid  bool
+	stopChan     chan bool
+	stopOnce     sync.Once
+}
+
+type RelayOption func(r *Relay)
+
+// NewRelay creates a relay of the outbox, reading up to 100 records
+// every second unless configured otherwise.
+func NewRelay(outbox *Outbox, bus messagebus.IMessageBus, opts ...RelayOption) (*Relay, error) {
+	relay := &Relay{
+		outbox:       outbox,
+		bus:          bus,
+		batchSize:    100,
+		pollInterval: time.Second,
+		stopChan:     make(chan bool),
+	}
+	for _, opt := range opts {
+		opt(relay)
+	}
+	if relay.batchSize <= 0 {
+		return nil, fmt.Errorf("batch size must be positive, got %d", relay.batchSize)
+	}
+	if relay.pollInterval <= 0 {
+		return nil, fmt.Errorf("poll interval must be positive, got %v", relay.pollInterval)
+	}
+	return relay, nil
+}
+
+// Configure the number of records read from the outbox at once
+func WithBatchSize(size int) RelayOption {
+	return func(r *Relay) {
+		r.batchSize = size
+	}
+}
+
+// Configure the interval the outbox is read at when it has no
+// pending record
+func WithPollInterval(interval time.Duration) RelayOption {
+	return func(r *Relay) {
+		r.pollInterval = interval
+	}
+}
+
+// Mark the records that cannot be rebuilt failed and send the ones
+// after them, giving up the order of records for their availability
+func WithSkipInvalidRecords() RelayOption {
+	return func(r *Relay) {
+		r.skipInvalid = true
+	}
+}
+
+// RelayPending sends the pending records of the outbox until none is
+// left or one fails to send or, unless skipped, to be rebuilt,
+// returning the number of records sent.
+func (r *Relay) RelayPending() (int, error) {
+	sent := 0
+	for {
+		records, err := r.outbox.pending(r.batchSize)
+		if err != nil {
+			return sent, err
+		}
+		for _, pending := range records {
+			if pending.err != nil {
+				if !r.skipInvalid {
+					return sent, pending.err
+				}
+				_, _ = fmt.Fprintln(os.Stderr, pending.err)
+				err = r.outbox.markFailed(pending.id)
+				if err != nil {
+					return sent, fmt.Errorf("cannot mark outbox record %d failed: %w", pending.id, err)
+				}
+				continue
+			}
+			_, err = r.bus.Send(pending.topic, pending.record)
+			if err != nil {
+				return sent, fmt.Errorf("cannot send outbox record %d: %w", pending.id, err)
+			}
+			err = r.outbox.markSent(pending.id)
+			if err != nil {
+				return sent, fmt.Errorf("cannot mark outbox record %d sent: %w", pending.id, err)
+			}
+			sent++
+		}
+		if len(records) < r.batchSize {
+			return sent, nil
+		}
+	}
+}
+
+// Start relays pending records in the background until Stop is
+// called. Errors are reported to stderr and retried at the next
+// poll.
+func (r *Relay) Start() {
+	go func() {
+		ticker := time.NewTicker(r.pollInterval)
+		defer ticker.Stop()
+		for {
+			_, err := r.RelayPending()
+			if err != nil {
+				_, _ = fmt.Fprintln(os.Stderr, err)
+			}
+			select {
+			case <-r.stopChan:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop stops relaying records started with Start. It returns at
+// once, and may be called even if Start was not.
+func (r *Relay) Stop() {
+	r.stopOnce.Do(func() {
+		close(r.stopChan)
+	})
+}
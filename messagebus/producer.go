@@ -7,6 +7,11 @@ import (
 type ProducerConfiguration struct {
 	FlushTimeoutMs int
 	KafkaConfig    *kafka.ConfigMap
+	// Signer signs the messages sent, when set
+	Signer ISigner
+	// ChunkSize splits the values larger than it into chunks of
+	// at most its size, when positive
+	ChunkSize int
 }
 
 type ProducerOption func(p *ProducerConfiguration)
@@ -126,3 +131,25 @@ func WithProducerSASLAuth(protocol SecurityProtocol, mechanism SASLMechanism, us
 		_ = p.KafkaConfig.SetKey("sasl.password", password)
 	}
 }
+
+// Configure messages to be signed, with the signature and the ID of
+// the signing key sent in headers
+// Example:
+// 		NewProducerConfig(WithSigner(NewEd25519Signer("billing-2024", privateKey)))
+func WithSigner(signer ISigner) ProducerOption {
+	return func(p *ProducerConfiguration) {
+		p.Signer = signer
+	}
+}
+
+// Configure values larger than chunkSize bytes to be sent as ordered
+// chunks of at most chunkSize bytes on the same partition, which
+// consumers reassemble before handling them. chunkSize must leave
+// room for the key and headers under message.max.bytes
+// Example:
+// 		NewProducerConfig(WithChunking(900 * 1024))
+func WithChunking(chunkSize int) ProducerOption {
+	return func(p *ProducerConfiguration) {
+		p.ChunkSize = chunkSize
+	}
+}
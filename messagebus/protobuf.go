@@ -0,0 +1,188 @@
+package messagebus
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bufbuild/protocompile"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoprint"
+	"github.com/kata-ai/messagebus-golang-kafka/messagebus/schemaregistry"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// protobufSchemaRegistry prints the .proto schemas of the Go
+// messages values are sent as, and compiles the .proto schemas
+// registered in Schema Registry into descriptors so that values
+// of unknown types can be decoded dynamically.
+type protobufSchemaRegistry struct {
+	printed         map[string]string
+	printedLock     sync.RWMutex
+	descriptors     map[int]protoreflect.FileDescriptor
+	descriptorsLock sync.RWMutex
+}
+
+func newProtobufSchemaRegistry() *protobufSchemaRegistry {
+	return &protobufSchemaRegistry{
+		printed:     make(map[string]string),
+		descriptors: make(map[int]protoreflect.FileDescriptor),
+	}
+}
+
+// schemaOf returns the .proto source of a file descriptor.
+func (r *protobufSchemaRegistry) schemaOf(file protoreflect.FileDescriptor) (string, error) {
+	r.printedLock.RLock()
+	schema, ok := r.printed[file.Path()]
+	r.printedLock.RUnlock()
+	if ok {
+		return schema, nil
+	}
+	wrapped, err := desc.WrapFile(file)
+	if err != nil {
+		return "", err
+	}
+	printer := protoprint.Printer{}
+	schema, err = printer.PrintProtoToString(wrapped)
+	if err != nil {
+		return "", err
+	}
+	r.printedLock.Lock()
+	r.printed[file.Path()] = schema
+	r.printedLock.Unlock()
+	return schema, nil
+}
+
+// descriptorOf compiles a registered .proto schema along with the
+// schemas it references, fetching them from Schema Registry.
+func (r *protobufSchemaRegistry) descriptorOf(client ISchemaRegistryClient, schema *schemaregistry.Schema) (protoreflect.FileDescriptor, error) {
+	r.descriptorsLock.RLock()
+	file := r.descriptors[schema.ID()]
+	r.descriptorsLock.RUnlock()
+	if file != nil {
+		return file, nil
+	}
+	name := strconv.Itoa(schema.ID()) + ".proto"
+	sources := map[string]string{name: schema.Schema()}
+	err := fetchProtobufReferences(client, schema.References(), sources)
+	if err != nil {
+		return nil, err
+	}
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(&protocompile.SourceResolver{
+			Accessor: protocompile.SourceAccessorFromMap(sources),
+		}),
+	}
+	files, err := compiler.Compile(context.Background(), name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Protobuf schema %d: %v", schema.ID(), err)
+	}
+	file = files[0]
+	r.descriptorsLock.Lock()
+	r.descriptors[schema.ID()] = file
+	r.descriptorsLock.Unlock()
+	return file, nil
+}
+
+func fetchProtobufReferences(client ISchemaRegistryClient, references []schemaregistry.Reference, sources map[string]string) error {
+	for _, reference := range references {
+		if _, ok := sources[reference.Name]; ok {
+			continue
+		}
+		schema, err := client.GetSchemaByVersion(reference.Subject, reference.Version)
+		if err != nil {
+			return fmt.Errorf("cannot fetch reference %s: %w", reference.Name, err)
+		}
+		sources[reference.Name] = schema.Schema()
+		err = fetchProtobufReferences(client, schema.References(), sources)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isWellKnownProtobufFile tells whether an import is one of the
+// well-known types, which Schema Registry resolves by itself.
+func isWellKnownProtobufFile(path string) bool {
+	return strings.HasPrefix(path, "google/protobuf/")
+}
+
+// protobufMessageIndexes returns the path of a message in its file,
+// as the indexes of the message and of the messages it is nested in.
+func protobufMessageIndexes(message protoreflect.MessageDescriptor) []int {
+	var indexes []int
+	var current protoreflect.Descriptor = message
+	for {
+		indexes = append([]int{current.Index()}, indexes...)
+		parent, ok := current.Parent().(protoreflect.MessageDescriptor)
+		if !ok {
+			return indexes
+		}
+		current = parent
+	}
+}
+
+// encodeMessageIndexes writes message indexes the way Confluent
+// serializers do, as zigzag varints prefixed with their count, and
+// as a single zero for the first message of a file.
+func encodeMessageIndexes(indexes []int) []byte {
+	if len(indexes) == 1 && indexes[0] == 0 {
+		return []byte{0}
+	}
+	buf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutVarint(buf, int64(len(indexes)))
+	encoded := append([]byte{}, buf[:n]...)
+	for _, index := range indexes {
+		n = binary.PutVarint(buf, int64(index))
+		encoded = append(encoded, buf[:n]...)
+	}
+	return encoded
+}
+
+// decodeMessageIndexes reads the message indexes that prefix a
+// Protobuf payload, returning them along with the message bytes.
+func decodeMessageIndexes(payload []byte) ([]int, []byte, error) {
+	reader := bytes.NewReader(payload)
+	count, err := binary.ReadVarint(reader)
+	if err != nil {
+		return nil, nil, errors.New("invalid Protobuf message indexes")
+	}
+	if count == 0 {
+		return []int{0}, payload[len(payload)-reader.Len():], nil
+	}
+	if count < 0 || count > int64(reader.Len()) {
+		return nil, nil, errors.New("invalid Protobuf message indexes")
+	}
+	indexes := make([]int, count)
+	for i := range indexes {
+		index, err := binary.ReadVarint(reader)
+		if err != nil || index < 0 {
+			return nil, nil, errors.New("invalid Protobuf message indexes")
+		}
+		indexes[i] = int(index)
+	}
+	return indexes, payload[len(payload)-reader.Len():], nil
+}
+
+// messageByIndexes finds the message a payload was written with.
+func messageByIndexes(file protoreflect.FileDescriptor, indexes []int) (protoreflect.MessageDescriptor, error) {
+	messages := file.Messages()
+	var message protoreflect.MessageDescriptor
+	for _, index := range indexes {
+		if index >= messages.Len() {
+			return nil, fmt.Errorf("no message at indexes %v of %s", indexes, file.Path())
+		}
+		message = messages.Get(index)
+		messages = message.Messages()
+	}
+	if message == nil {
+		return nil, fmt.Errorf("no message at indexes %v of %s", indexes, file.Path())
+	}
+	return message, nil
+}
@@ -0,0 +1,97 @@
+package messagebus
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bufbuild/protocompile"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/kata-ai/messagebus-golang-kafka/messagebus/schemaregistry"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+var nestedProtoSources = map[string]string{
+	"common/money.proto": `syntax = "proto3";
+package common;
+
+message Money {
+  string currency = 1;
+  int64 units = 2;
+}
+`,
+	"orders/order.proto": `syntax = "proto3";
+package orders;
+
+import "common/money.proto";
+
+message Order {
+  string id = 1;
+  common.Money total = 2;
+}
+`,
+}
+
+func compileOrderMessage(t *testing.T) protoreflect.MessageDescriptor {
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(&protocompile.SourceResolver{
+			Accessor: protocompile.SourceAccessorFromMap(nestedProtoSources),
+		}),
+	}
+	files, err := compiler.Compile(context.Background(), "orders/order.proto")
+	if err != nil {
+		t.Fatalf("cannot compile order.proto: %v", err)
+	}
+	return files[0].Messages().ByName("Order")
+}
+
+func TestProtobufNestedImport(t *testing.T) {
+	registry := schemaregistry.NewMockClient()
+	server := schemaregistry.NewFakeServer(registry)
+	defer server.Close()
+
+	descriptor := compileOrderMessage(t)
+	order := dynamicpb.NewMessage(descriptor)
+	order.Set(descriptor.Fields().ByName("id"), protoreflect.ValueOfString("order-1"))
+	money := descriptor.Fields().ByName("total").Message()
+	total := dynamicpb.NewMessage(money)
+	total.Set(money.Fields().ByName("currency"), protoreflect.ValueOfString("IDR"))
+	total.Set(money.Fields().ByName("units"), protoreflect.ValueOfInt64(15000))
+	order.Set(descriptor.Fields().ByName("total"), protoreflect.ValueOfMessage(total))
+
+	producer, err := NewSerializer(server.URL, TOPIC_NAME_STRATEGY, WithHeaderEnvelope(true))
+	if err != nil {
+		t.Fatalf("cannot create serializer: %v", err)
+	}
+	key, err := NewMessageKey("order-service")
+	if err != nil {
+		t.Fatalf("cannot create message key: %v", err)
+	}
+	serialized, err := producer.Serialize("orders", NewProducerRecord(key, order))
+	if err != nil {
+		t.Fatalf("cannot serialize order: %v", err)
+	}
+	if _, err := registry.GetLatestSchema("common/money.proto"); err != nil {
+		t.Errorf("imported file is not registered under its path: %v", err)
+	}
+
+	// A separate serializer fetches the schema and its reference
+	consumer, err := NewSerializer(server.URL, TOPIC_NAME_STRATEGY, WithHeaderEnvelope(true))
+	if err != nil {
+		t.Fatalf("cannot create serializer: %v", err)
+	}
+	topic := "orders"
+	record, err := consumer.Deserialize(&kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic},
+		Key:            serialized.Key,
+		Value:          serialized.Value,
+		Headers:        serialized.Headers,
+	})
+	if err != nil {
+		t.Fatalf("cannot deserialize order: %v", err)
+	}
+	totalValue, ok := record.Value["total"].(map[string]interface{})
+	if record.Value["id"] != "order-1" || !ok || totalValue["currency"] != "IDR" || totalValue["units"] != "15000" {
+		t.Errorf("deserialized order is %v", record.Value)
+	}
+}
@@ -0,0 +1,82 @@
+package messagebus
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/kata-ai/messagebus-golang-kafka/messagebus/internal/avro"
+	"github.com/linkedin/goavro/v2"
+)
+
+// readerSchemaRegistry keeps the reader schemas consumers have
+// declared per topic, along with the projections resolving each
+// writer schema into them.
+type readerSchemaRegistry struct {
+	schemas         map[string]*readerSchema
+	schemasLock     sync.RWMutex
+	resolutions     map[string]*resolution
+	resolutionsLock sync.RWMutex
+}
+
+type readerSchema struct {
+	schema string
+	codec  *goavro.Codec
+}
+
+type resolution struct {
+	project avro.Projection
+	err     error
+}
+
+func newReaderSchemaRegistry() *readerSchemaRegistry {
+	return &readerSchemaRegistry{
+		schemas:     make(map[string]*readerSchema),
+		resolutions: make(map[string]*resolution),
+	}
+}
+
+func (r *readerSchemaRegistry) register(topic string, schema string) error {
+	codec, err := goavro.NewCodec(schema)
+	if err != nil {
+		return fmt.Errorf("invalid reader schema for topic %s: %v", topic, err)
+	}
+	r.schemasLock.Lock()
+	r.schemas[topic] = &readerSchema{schema: schema, codec: codec}
+	r.schemasLock.Unlock()
+
+	// Drop resolutions made against a previous reader schema
+	r.resolutionsLock.Lock()
+	r.resolutions = make(map[string]*resolution)
+	r.resolutionsLock.Unlock()
+	return nil
+}
+
+func (r *readerSchemaRegistry) lookup(topic string) *readerSchema {
+	r.schemasLock.RLock()
+	defer r.schemasLock.RUnlock()
+	return r.schemas[topic]
+}
+
+// project converts a native value written with the writer schema
+// into the reader schema of the topic. Resolutions are computed once
+// per writer schema, including the ones that turn out incompatible.
+func (r *readerSchemaRegistry) project(topic string, reader *readerSchema, writer *Schema, native interface{}) (interface{}, error) {
+	key := fmt.Sprintf("%s-%d", topic, writer.ID())
+	r.resolutionsLock.RLock()
+	resolved := r.resolutions[key]
+	r.resolutionsLock.RUnlock()
+	if resolved == nil {
+		project, err := avro.Resolve(writer.Schema(), reader.schema)
+		if err != nil {
+			err = fmt.Errorf("schema %d is incompatible with reader schema of topic %s: %v", writer.ID(), topic, err)
+		}
+		resolved = &resolution{project: project, err: err}
+		r.resolutionsLock.Lock()
+		r.resolutions[key] = resolved
+		r.resolutionsLock.Unlock()
+	}
+	if resolved.err != nil {
+		return nil, resolved.err
+	}
+	return resolved.project(native)
+}
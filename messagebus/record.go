@@ -2,16 +2,20 @@ package messagebus
 
 import (
 	"time"
-
-	"github.com/actgardner/gogen-avro/v7/container"
 )
 
+// ProducerRecord holds a value that is either a gogen-avro record,
+// written with Avro, or any other Go value, written with JSON Schema
 type ProducerRecord struct {
 	Key   *MessageKey
-	Value container.AvroRecord
+	Value interface{}
+	// RecordKey is the Kafka record key, such as a business key
+	// partitioning and compacting the topic. It is only written
+	// when the message key travels in headers
+	RecordKey []byte
 }
 
-func NewProducerRecord(key *MessageKey, value container.AvroRecord) *ProducerRecord {
+func NewProducerRecord(key *MessageKey, value interface{}) *ProducerRecord {
 	return &ProducerRecord{
 		Key:   key,
 		Value: value,
@@ -19,10 +23,18 @@ func NewProducerRecord(key *MessageKey, value container.AvroRecord) *ProducerRec
 }
 
 type ConsumerRecord struct {
-	Key       *MessageKey
+	Key *MessageKey
+	// RecordKey is the Kafka record key of messages whose message
+	// key travels in headers, and is nil for Avro message keys
+	RecordKey []byte
 	Topic     string
 	Value     map[string]interface{}
 	Partition int32
 	Offset    string
 	Timestamp time.Time
+	// Record holds the decoded value when a value type has been
+	// registered for the topic or value subject, in which case
+	// Value is left nil. It is a gogen-avro record for Avro
+	// values and the registered Go type for JSON Schema values
+	Record interface{}
 }
@@ -0,0 +1,15 @@
+package messagebus
+
+import "github.com/kata-ai/messagebus-golang-kafka/messagebus/schemaregistry"
+
+// Schema and SchemaType moved to the schemaregistry package,
+// and are kept here for code written against earlier releases.
+type Schema = schemaregistry.Schema
+
+type SchemaType = schemaregistry.SchemaType
+
+const (
+	Protobuf = schemaregistry.Protobuf
+	Avro     = schemaregistry.Avro
+	Json     = schemaregistry.Json
+)
@@ -0,0 +1,85 @@
+package messagebus
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sync"
+)
+
+// schemaIDCache remembers the IDs of the schemas this process has
+// registered or looked up, keyed by subject and schema fingerprint,
+// so that each schema reaches Schema Registry only once. Concurrent
+// lookups of a schema wait for the first one instead of repeating it.
+type schemaIDCache struct {
+	schemas  map[string]*registeredSchema
+	inFlight map[string]*schemaIDCall
+	lock     sync.Mutex
+}
+
+type schemaIDCall struct {
+	done   chan struct{}
+	schema *registeredSchema
+	err    error
+}
+
+// registeredSchema is the schema records are written with.
+type registeredSchema struct {
+	id int
+	// transcode converts records into the registered schema when
+	// it differs from the schema of the record, and is nil otherwise
+	transcode func(payload []byte) ([]byte, error)
+	// validate checks JSON payloads against the registered JSON
+	// Schema, and is nil for Avro schemas
+	validate func(payload []byte) error
+}
+
+type fingerprinted interface {
+	AvroCRC64Fingerprint() []byte
+}
+
+func newSchemaIDCache() *schemaIDCache {
+	return &schemaIDCache{
+		schemas:  make(map[string]*registeredSchema),
+		inFlight: make(map[string]*schemaIDCall),
+	}
+}
+
+// get returns the cached schema under the subject, calling fetch
+// when it is unknown. Failed fetches are not cached.
+func (c *schemaIDCache) get(subject string, fingerprint []byte, fetch func() (*registeredSchema, error)) (*registeredSchema, error) {
+	key := fmt.Sprintf("%s-%x", subject, fingerprint)
+	c.lock.Lock()
+	if schema, ok := c.schemas[key]; ok {
+		c.lock.Unlock()
+		return schema, nil
+	}
+	if call, ok := c.inFlight[key]; ok {
+		c.lock.Unlock()
+		<-call.done
+		return call.schema, call.err
+	}
+	call := &schemaIDCall{done: make(chan struct{})}
+	c.inFlight[key] = call
+	c.lock.Unlock()
+
+	call.schema, call.err = fetch()
+
+	c.lock.Lock()
+	if call.err == nil {
+		c.schemas[key] = call.schema
+	}
+	delete(c.inFlight, key)
+	c.lock.Unlock()
+	close(call.done)
+	return call.schema, call.err
+}
+
+// schemaFingerprint uses the fingerprint gogen-avro generates for
+// the record, and hashes the schema for records that lack one.
+func schemaFingerprint(record interface{ Schema() string }) []byte {
+	if f, ok := record.(fingerprinted); ok {
+		return f.AvroCRC64Fingerprint()
+	}
+	sum := sha256.Sum256([]byte(record.Schema()))
+	return sum[:]
+}
@@ -0,0 +1,76 @@
+package messagebus
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kata-ai/messagebus-golang-kafka/messagebus/schemaregistry"
+)
+
+// countingClient counts the schemas registered through it, taking
+// some time to register them so that concurrent calls overlap.
+type countingClient struct {
+	*schemaregistry.MockClient
+	creates int32
+}
+
+func (c *countingClient) CreateSchema(subject string, schema string, schemaType schemaregistry.SchemaType, references ...schemaregistry.Reference) (*schemaregistry.Schema, error) {
+	atomic.AddInt32(&c.creates, 1)
+	time.Sleep(10 * time.Millisecond)
+	return c.MockClient.CreateSchema(subject, schema, schemaType, references...)
+}
+
+func TestSchemaIDCacheSends(t *testing.T) {
+	client := &countingClient{MockClient: schemaregistry.NewMockClient()}
+	serializer, err := NewSerializerWithClient(client, TOPIC_NAME_STRATEGY)
+	if err != nil {
+		t.Fatalf("cannot create serializer: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		key, err := NewMessageKey("order-service")
+		if err != nil {
+			t.Fatalf("cannot create message key: %v", err)
+		}
+		if _, err := serializer.Serialize("orders", NewProducerRecord(key, &keyedOrder{Id: "order-1"})); err != nil {
+			t.Fatalf("cannot serialize record: %v", err)
+		}
+	}
+	// The key and value schemas are registered once each
+	if creates := atomic.LoadInt32(&client.creates); creates != 2 {
+		t.Errorf("registered schemas %d times, want 2", creates)
+	}
+}
+
+func TestSchemaIDCacheConcurrentLookups(t *testing.T) {
+	client := &countingClient{MockClient: schemaregistry.NewMockClient()}
+	serializer, err := NewSerializerWithClient(client, TOPIC_NAME_STRATEGY)
+	if err != nil {
+		t.Fatalf("cannot create serializer: %v", err)
+	}
+	var wg sync.WaitGroup
+	ids := make([]int, 10)
+	for i := range ids {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			schema, err := serializer.resolveSchema("orders-key", &MessageKey{})
+			if err != nil {
+				t.Errorf("cannot resolve schema: %v", err)
+				return
+			}
+			ids[i] = schema.id
+		}(i)
+	}
+	wg.Wait()
+	if creates := atomic.LoadInt32(&client.creates); creates != 1 {
+		t.Errorf("registered schema %d times, want once", creates)
+	}
+	for _, id := range ids {
+		if id != ids[0] {
+			t.Errorf("resolved IDs %v, want the same", ids)
+			break
+		}
+	}
+}
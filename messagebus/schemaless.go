@@ -0,0 +1,186 @@
+package messagebus
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/actgardner/gogen-avro/v7/container"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// errNoReaderSchema is returned when reader schemas are registered
+// with serializers that do not use schemas.
+var errNoReaderSchema = errors.New("reader schemas only apply to Schema Registry topics")
+
+// JSONSerializer writes values as plain JSON without Schema Registry,
+// for topics shared with systems that do not use it. The message key
+// is carried in headers rather than in the Kafka key.
+// Example:
+// 		WithTopicSerializer("legacy-orders", NewJSONSerializer())
+type JSONSerializer struct {
+	valueTypes *valueTypeRegistry
+}
+
+func NewJSONSerializer() *JSONSerializer {
+	return &JSONSerializer{valueTypes: newValueTypeRegistry()}
+}
+
+func (s JSONSerializer) Serialize(topic string, record *ProducerRecord) (*SerializedProducerRecord, error) {
+	var value []byte
+	var err error
+	if message, ok := record.Value.(proto.Message); ok {
+		value, err = protojson.Marshal(message)
+	} else {
+		value, err = json.Marshal(record.Value)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &SerializedProducerRecord{
+		Key:     record.RecordKey,
+		Value:   value,
+		Headers: schemalessKeyHeaders(record.Key),
+	}, nil
+}
+
+// Deserialize decodes values into the type registered for the topic,
+// or into a generic map for JSON objects. Other JSON values without
+// a registered type are decoded into ConsumerRecord.Record.
+func (s JSONSerializer) Deserialize(message *kafka.Message) (*ConsumerRecord, error) {
+	key, err := messageKeyFromHeaders(message.Headers)
+	if err != nil {
+		return nil, err
+	}
+	consumerRecord := newSchemalessRecord(message, key)
+	if message.Value == nil {
+		return consumerRecord, nil
+	}
+	if newValue := s.valueTypes.lookup(consumerRecord.Topic, ""); newValue != nil {
+		typedValue := newValue()
+		if typedMessage, ok := typedValue.(proto.Message); ok {
+			err = protojson.Unmarshal(message.Value, typedMessage)
+		} else {
+			err = json.Unmarshal(message.Value, typedValue)
+		}
+		if err != nil {
+			return nil, err
+		}
+		consumerRecord.Record = typedValue
+		return consumerRecord, nil
+	}
+	var value interface{}
+	err = json.Unmarshal(message.Value, &value)
+	if err != nil {
+		return nil, err
+	}
+	if object, ok := value.(map[string]interface{}); ok {
+		consumerRecord.Value = object
+	} else {
+		consumerRecord.Record = value
+	}
+	return consumerRecord, nil
+}
+
+// RegisterValueType decodes values of a topic into a gogen-avro
+// record, through the json tags of the generated struct.
+func (s JSONSerializer) RegisterValueType(topic string, newRecord func() container.AvroRecord) {
+	s.valueTypes.register(topic, func() interface{} {
+		return newRecord()
+	})
+}
+
+func (s JSONSerializer) RegisterJSONValueType(topic string, newValue func() interface{}) {
+	s.valueTypes.register(topic, newValue)
+}
+
+// RegisterProtobufValueType decodes values of a topic into a Go
+// message, with the JSON mapping of Protobuf.
+func (s JSONSerializer) RegisterProtobufValueType(topic string, newMessage func() proto.Message) {
+	s.valueTypes.register(topic, func() interface{} {
+		return newMessage()
+	})
+}
+
+func (s JSONSerializer) RegisterReaderSchema(topic string, schema string) error {
+	return errNoReaderSchema
+}
+
+// RawSerializer passes values through as raw bytes, for topics
+// carrying payloads of other systems. Values sent must be []byte
+// or string, and consumed values are []byte held in
+// ConsumerRecord.Record. The message key is carried in headers.
+// Example:
+// 		WithTopicSerializer("sensor-frames", NewRawSerializer())
+type RawSerializer struct{}
+
+func NewRawSerializer() *RawSerializer {
+	return &RawSerializer{}
+}
+
+func (s RawSerializer) Serialize(topic string, record *ProducerRecord) (*SerializedProducerRecord, error) {
+	var value []byte
+	switch v := record.Value.(type) {
+	case []byte:
+		value = v
+	case string:
+		value = []byte(v)
+	default:
+		return nil, fmt.Errorf("raw topic %s only accepts []byte or string values, got %T", topic, record.Value)
+	}
+	return &SerializedProducerRecord{
+		Key:     record.RecordKey,
+		Value:   value,
+		Headers: schemalessKeyHeaders(record.Key),
+	}, nil
+}
+
+func (s RawSerializer) Deserialize(message *kafka.Message) (*ConsumerRecord, error) {
+	key, err := messageKeyFromHeaders(message.Headers)
+	if err != nil {
+		return nil, err
+	}
+	consumerRecord := newSchemalessRecord(message, key)
+	if message.Value != nil {
+		consumerRecord.Record = message.Value
+	}
+	return consumerRecord, nil
+}
+
+// RegisterValueType has no effect, as raw values are always []byte
+func (s RawSerializer) RegisterValueType(topic string, newRecord func() container.AvroRecord) {}
+
+// RegisterJSONValueType has no effect, as raw values are always []byte
+func (s RawSerializer) RegisterJSONValueType(topic string, newValue func() interface{}) {}
+
+// RegisterProtobufValueType has no effect, as raw values are always []byte
+func (s RawSerializer) RegisterProtobufValueType(topic string, newMessage func() proto.Message) {}
+
+func (s RawSerializer) RegisterReaderSchema(topic string, schema string) error {
+	return errNoReaderSchema
+}
+
+func newSchemalessRecord(message *kafka.Message, key *MessageKey) *ConsumerRecord {
+	return &ConsumerRecord{
+		Key:       key,
+		RecordKey: message.Key,
+		Topic:     *message.TopicPartition.Topic,
+		Partition: message.TopicPartition.Partition,
+		Offset:    message.TopicPartition.Offset.String(),
+		Timestamp: message.Timestamp,
+	}
+}
+
+// schemalessKeyHeaders carries the message key in headers, without
+// the value subject as schemaless values have none. The key of the
+// caller is left as is, as it may be sent to other topics.
+func schemalessKeyHeaders(key *MessageKey) []kafka.Header {
+	if key == nil {
+		return nil
+	}
+	schemalessKey := *key
+	schemalessKey.ValueSubject = ""
+	return messageKeyHeaders(&schemalessKey)
+}
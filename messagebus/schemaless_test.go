@@ -0,0 +1,111 @@
+package messagebus
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+func roundTrip(t *testing.T, serializer ISerializer, record *ProducerRecord) *ConsumerRecord {
+	t.Helper()
+	serialized, err := serializer.Serialize("orders", record)
+	if err != nil {
+		t.Fatalf("cannot serialize record: %v", err)
+	}
+	topic := "orders"
+	consumed, err := serializer.Deserialize(&kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic},
+		Key:            serialized.Key,
+		Value:          serialized.Value,
+		Headers:        serialized.Headers,
+	})
+	if err != nil {
+		t.Fatalf("cannot deserialize record: %v", err)
+	}
+	return consumed
+}
+
+func TestJSONSerializerRoundTrip(t *testing.T) {
+	typed := NewJSONSerializer()
+	typed.RegisterJSONValueType("orders", func() interface{} { return &keyedOrder{} })
+	tests := []struct {
+		name       string
+		serializer *JSONSerializer
+		value      interface{}
+		want       map[string]interface{}
+		wantRecord interface{}
+	}{
+		{"registered type", typed, &keyedOrder{Id: "order-1"}, nil, &keyedOrder{Id: "order-1"}},
+		{"object", NewJSONSerializer(), &keyedOrder{Id: "order-1"}, map[string]interface{}{"id": "order-1"}, nil},
+		{"array", NewJSONSerializer(), []string{"order-1"}, nil, []interface{}{"order-1"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			key, err := NewMessageKey("order-service")
+			if err != nil {
+				t.Fatalf("cannot create message key: %v", err)
+			}
+			record := NewProducerRecord(key, test.value)
+			record.RecordKey = []byte("order-1")
+			consumed := roundTrip(t, test.serializer, record)
+			if !reflect.DeepEqual(consumed.Key, key) || string(consumed.RecordKey) != "order-1" {
+				t.Errorf("key is %+v and record key %q, want %+v", consumed.Key, consumed.RecordKey, key)
+			}
+			if !reflect.DeepEqual(consumed.Value, test.want) || !reflect.DeepEqual(consumed.Record, test.wantRecord) {
+				t.Errorf("value is %v and record %v", consumed.Value, consumed.Record)
+			}
+		})
+	}
+}
+
+func TestRawSerializerRoundTrip(t *testing.T) {
+	for _, value := range []interface{}{[]byte("frame"), "frame"} {
+		key, err := NewMessageKey("sensor-service")
+		if err != nil {
+			t.Fatalf("cannot create message key: %v", err)
+		}
+		consumed := roundTrip(t, NewRawSerializer(), NewProducerRecord(key, value))
+		if !reflect.DeepEqual(consumed.Key, key) || !reflect.DeepEqual(consumed.Record, []byte("frame")) {
+			t.Errorf("consumed %+v and %v from %T", consumed.Key, consumed.Record, value)
+		}
+	}
+	if _, err := NewRawSerializer().Serialize("orders", NewProducerRecord(nil, 42)); err == nil {
+		t.Error("serialized an int as raw bytes")
+	}
+}
+
+func TestSchemalessWithoutKeyHeaders(t *testing.T) {
+	topic := "orders"
+	message := &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic},
+		Value:          []byte(`{"id":"order-1"}`),
+	}
+	for _, serializer := range []ISerializer{NewJSONSerializer(), NewRawSerializer()} {
+		consumed, err := serializer.Deserialize(message)
+		if err != nil {
+			t.Fatalf("cannot deserialize record: %v", err)
+		}
+		if consumed.Key != nil || consumed.RecordKey != nil {
+			t.Errorf("%T read key %+v", serializer, consumed.Key)
+		}
+	}
+}
+
+func TestSchemalessKeepsValueSubject(t *testing.T) {
+	key, err := NewMessageKey("order-service")
+	if err != nil {
+		t.Fatalf("cannot create message key: %v", err)
+	}
+	key.SetValueSubject("orders-value")
+	serialized, err := NewJSONSerializer().Serialize("legacy-orders", NewProducerRecord(key, &keyedOrder{Id: "order-1"}))
+	if err != nil {
+		t.Fatalf("cannot serialize record: %v", err)
+	}
+	if key.ValueSubject != "orders-value" {
+		t.Errorf("value subject of the key is %q", key.ValueSubject)
+	}
+	if value := headerValue(serialized.Headers, keyHeaderPrefix+"valueSubject"); value != "" {
+		t.Errorf("value subject header is %q", value)
+	}
+}
@@ -0,0 +1,301 @@
+package schemaregistry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+)
+
+// ErrReadOnlyBundle is returned by the methods of BundleClient
+// that would change the schemas of the bundle.
+var ErrReadOnlyBundle = errors.New("schema bundle is read-only")
+
+// BundleClient is an IClient that resolves schemas from a bundle
+// of schema files with pinned IDs instead of Schema Registry, so
+// that Confluent wire-format messages can be written and read
+// without any registry. The bundle is described by a lock file:
+//
+// 		{
+// 			"compatibility": "BACKWARD",
+// 			"schemas": [
+// 				{"subject": "orders-value", "version": 1, "id": 12, "file": "orders.avsc"},
+// 				{"subject": "orders-value", "version": 2, "id": 15, "file": "orders_v2.avsc"}
+// 			]
+// 		}
+//
+// Files are relative to the lock file, and schemas are Avro unless
+// a "schemaType" is given. Registering a schema only succeeds when
+// the bundle pins it under the subject already.
+type BundleClient struct {
+	ids      map[int]*Schema
+	subjects map[string][]*Schema
+	level    CompatibilityLevel
+}
+
+type bundleLock struct {
+	Compatibility CompatibilityLevel `json:"compatibility"`
+	Schemas       []bundleEntry      `json:"schemas"`
+}
+
+type bundleEntry struct {
+	Subject    string      `json:"subject"`
+	Version    int         `json:"version"`
+	ID         int         `json:"id"`
+	File       string      `json:"file"`
+	SchemaType SchemaType  `json:"schemaType"`
+	References []Reference `json:"references"`
+}
+
+// NewBundleClient loads the bundle described by the lock file of
+// fsys, which can be an embed.FS, os.DirFS or a statik file system
+// wrapped with HTTPFileSystem.
+func NewBundleClient(fsys fs.FS, lockFile string) (*BundleClient, error) {
+	lockBytes, err := fs.ReadFile(fsys, lockFile)
+	if err != nil {
+		return nil, err
+	}
+	var lock bundleLock
+	err = json.Unmarshal(lockBytes, &lock)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema lock file %s: %v", lockFile, err)
+	}
+	client := &BundleClient{
+		ids:      make(map[int]*Schema),
+		subjects: make(map[string][]*Schema),
+		level:    lock.Compatibility,
+	}
+	if client.level == "" {
+		client.level = Backward
+	}
+	for _, entry := range lock.Schemas {
+		err = client.add(fsys, path.Dir(lockFile), entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schema %s version %d: %v", entry.Subject, entry.Version, err)
+		}
+	}
+	for _, versions := range client.subjects {
+		sort.Slice(versions, func(i, j int) bool {
+			return versions[i].version < versions[j].version
+		})
+	}
+	return client, nil
+}
+
+func (client *BundleClient) add(fsys fs.FS, dir string, entry bundleEntry) error {
+	if entry.Subject == "" || entry.Version <= 0 || entry.ID <= 0 || entry.File == "" {
+		return errors.New("subject, version, id and file are required")
+	}
+	schemaBytes, err := fs.ReadFile(fsys, path.Join(dir, entry.File))
+	if err != nil {
+		return err
+	}
+	schema, err := NewSchema(entry.ID, entry.Subject, entry.Version, string(schemaBytes), entry.SchemaType, entry.References)
+	if err != nil {
+		return err
+	}
+	canonical, err := canonicalSchema(schema.schema, schema.schemaType)
+	if err != nil {
+		return err
+	}
+	for _, existing := range client.subjects[entry.Subject] {
+		if existing.version == entry.Version {
+			return errors.New("version is pinned twice")
+		}
+	}
+	if existing, ok := client.ids[entry.ID]; ok {
+		if !schemaMatches(existing, canonical, schema.schemaType, schema.references) {
+			return fmt.Errorf("id %d is pinned to another schema", entry.ID)
+		}
+	} else {
+		byID, _ := NewSchema(entry.ID, "", 0, schema.schema, schema.schemaType, schema.references)
+		client.ids[entry.ID] = byID
+	}
+	client.subjects[entry.Subject] = append(client.subjects[entry.Subject], schema)
+	return nil
+}
+
+// GetSchema gets the schema pinned to the given id.
+func (client *BundleClient) GetSchema(schemaID int) (*Schema, error) {
+	schema, ok := client.ids[schemaID]
+	if !ok {
+		return nil, notFoundError(40403, "Schema %d not found", schemaID)
+	}
+	return schema, nil
+}
+
+// GetLatestSchema gets the last version pinned under the subject.
+func (client *BundleClient) GetLatestSchema(subject string) (*Schema, error) {
+	versions, err := client.versions(subject)
+	if err != nil {
+		return nil, err
+	}
+	return versions[len(versions)-1], nil
+}
+
+// GetSchemaVersions returns the versions pinned under the subject.
+func (client *BundleClient) GetSchemaVersions(subject string) ([]int, error) {
+	versions, err := client.versions(subject)
+	if err != nil {
+		return nil, err
+	}
+	var numbers []int
+	for _, version := range versions {
+		numbers = append(numbers, version.version)
+	}
+	return numbers, nil
+}
+
+// GetSchemaByVersion gets a version pinned under the subject.
+func (client *BundleClient) GetSchemaByVersion(subject string, version int) (*Schema, error) {
+	versions, err := client.versions(subject)
+	if err != nil {
+		return nil, err
+	}
+	for _, found := range versions {
+		if found.version == version {
+			return found, nil
+		}
+	}
+	return nil, notFoundError(40402, "Version %d not found.", version)
+}
+
+// CreateSchema returns the version of the subject pinned to the
+// schema, as the bundle cannot register new schemas.
+func (client *BundleClient) CreateSchema(subject string, schema string,
+	schemaType SchemaType, references ...Reference) (*Schema, error) {
+	return client.LookupSchema(subject, schema, schemaType, references...)
+}
+
+// LookupSchema returns the version of the subject pinned to the schema.
+func (client *BundleClient) LookupSchema(subject string, schema string, schemaType SchemaType, references ...Reference) (*Schema, error) {
+	if schemaType == "" {
+		schemaType = Avro
+	}
+	canonical, err := canonicalSchema(schema, schemaType)
+	if err != nil {
+		return nil, err
+	}
+	versions, err := client.versions(subject)
+	if err != nil {
+		return nil, err
+	}
+	for _, version := range versions {
+		if schemaMatches(version, canonical, schemaType, references) {
+			return version, nil
+		}
+	}
+	return nil, notFoundError(40403, "Schema not found in bundle under subject '%s'", subject)
+}
+
+// TestCompatibility checks the schema against the latest version
+// pinned under the subject, following the level of the lock file.
+// Like Schema Registry, it fails with a 404 for unknown subjects.
+func (client *BundleClient) TestCompatibility(subject string, schema string, schemaType SchemaType, references ...Reference) (bool, error) {
+	versions, err := client.versions(subject)
+	if err != nil {
+		return false, err
+	}
+	return client.testCompatibility(schema, schemaType, versions)
+}
+
+// TestCompatibilityByVersion checks the schema against a version
+// pinned under the subject.
+func (client *BundleClient) TestCompatibilityByVersion(subject string, version int, schema string, schemaType SchemaType, references ...Reference) (bool, error) {
+	found, err := client.GetSchemaByVersion(subject, version)
+	if err != nil {
+		return false, err
+	}
+	return client.testCompatibility(schema, schemaType, []*Schema{found})
+}
+
+func (client *BundleClient) testCompatibility(schema string, schemaType SchemaType, versions []*Schema) (bool, error) {
+	if schemaType == "" {
+		schemaType = Avro
+	}
+	if _, err := canonicalSchema(schema, schemaType); err != nil {
+		return false, err
+	}
+	err := checkCompatibility(client.level, schema, schemaType, versions)
+	if isIncompatible(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// GetCompatibilityLevel returns the level of the lock file.
+func (client *BundleClient) GetCompatibilityLevel(subject string) (CompatibilityLevel, error) {
+	return client.level, nil
+}
+
+// SetCompatibilityLevel fails with ErrReadOnlyBundle.
+func (client *BundleClient) SetCompatibilityLevel(subject string, level CompatibilityLevel) error {
+	return ErrReadOnlyBundle
+}
+
+// GetGlobalCompatibilityLevel returns the level of the lock file.
+func (client *BundleClient) GetGlobalCompatibilityLevel() (CompatibilityLevel, error) {
+	return client.level, nil
+}
+
+// SetGlobalCompatibilityLevel fails with ErrReadOnlyBundle.
+func (client *BundleClient) SetGlobalCompatibilityLevel(level CompatibilityLevel) error {
+	return ErrReadOnlyBundle
+}
+
+// GetSubjects returns the subjects of the bundle.
+func (client *BundleClient) GetSubjects() ([]string, error) {
+	subjects := []string{}
+	for subject := range client.subjects {
+		subjects = append(subjects, subject)
+	}
+	sort.Strings(subjects)
+	return subjects, nil
+}
+
+// DeleteSubject fails with ErrReadOnlyBundle.
+func (client *BundleClient) DeleteSubject(subject string, permanent bool) ([]int, error) {
+	return nil, ErrReadOnlyBundle
+}
+
+// DeleteSchemaVersion fails with ErrReadOnlyBundle.
+func (client *BundleClient) DeleteSchemaVersion(subject string, version int, permanent bool) (int, error) {
+	return 0, ErrReadOnlyBundle
+}
+
+func (client *BundleClient) versions(subject string) ([]*Schema, error) {
+	versions := client.subjects[subject]
+	if len(versions) == 0 {
+		return nil, notFoundError(40401, "Subject '%s' not found.", subject)
+	}
+	return versions, nil
+}
+
+// HTTPFileSystem adapts an http.FileSystem, such as the one
+// the statik package generates, to the fs.FS bundles are
+// loaded from.
+// Example:
+// 		statikFS, err := fs.New()
+// 		client, err := schemaregistry.NewBundleClient(schemaregistry.HTTPFileSystem(statikFS), "schemas.lock.json")
+func HTTPFileSystem(fsys http.FileSystem) fs.FS {
+	return httpFS{fsys: fsys}
+}
+
+type httpFS struct {
+	fsys http.FileSystem
+}
+
+func (h httpFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) || strings.Contains(name, "\\") {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	file, err := h.fsys.Open("/" + name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return file, nil
+}
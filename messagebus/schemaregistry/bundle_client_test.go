@@ -0,0 +1,148 @@
+package schemaregistry
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"testing/fstest"
+)
+
+const bundleLockFile = `{
+	"compatibility": "BACKWARD",
+	"schemas": [
+		{"subject": "users-value", "version": 2, "id": 15, "file": "users_v2.avsc"},
+		{"subject": "users-value", "version": 1, "id": 12, "file": "users.avsc"},
+		{"subject": "members-value", "version": 1, "id": 12, "file": "users.avsc"}
+	]
+}`
+
+func newTestBundle(t *testing.T) *BundleClient {
+	client, err := NewBundleClient(fstest.MapFS{
+		"schemas/schemas.lock.json": {Data: []byte(bundleLockFile)},
+		"schemas/users.avsc":        {Data: []byte(userSchemaV1)},
+		"schemas/users_v2.avsc":     {Data: []byte(userSchemaV2)},
+	}, "schemas/schemas.lock.json")
+	if err != nil {
+		t.Fatalf("cannot load bundle: %v", err)
+	}
+	return client
+}
+
+func assertNotFound(t *testing.T, err error) {
+	t.Helper()
+	var registryErr *Error
+	if !errors.As(err, &registryErr) || registryErr.StatusCode != http.StatusNotFound {
+		t.Errorf("error is %v, want a 404", err)
+	}
+}
+
+func TestBundleClientPinnedIDs(t *testing.T) {
+	client := newTestBundle(t)
+	for id, want := range map[int]string{12: userSchemaV1, 15: userSchemaV2} {
+		schema, err := client.GetSchema(id)
+		if err != nil {
+			t.Fatalf("cannot get schema %d: %v", id, err)
+		}
+		if schema.Schema() != want {
+			t.Errorf("schema %d is %s", id, schema.Schema())
+		}
+	}
+	latest, err := client.GetLatestSchema("users-value")
+	if err != nil || latest.ID() != 15 || latest.Version() != 2 {
+		t.Errorf("latest schema is %+v, %v, want ID 15 version 2", latest, err)
+	}
+	versions, err := client.GetSchemaVersions("users-value")
+	if err != nil || len(versions) != 2 || versions[0] != 1 || versions[1] != 2 {
+		t.Errorf("versions are %v, %v", versions, err)
+	}
+	subjects, err := client.GetSubjects()
+	if err != nil || len(subjects) != 2 || subjects[0] != "members-value" || subjects[1] != "users-value" {
+		t.Errorf("subjects are %v, %v", subjects, err)
+	}
+}
+
+func TestBundleClientRegistration(t *testing.T) {
+	client := newTestBundle(t)
+	tests := []struct {
+		name    string
+		subject string
+		schema  string
+		id      int
+	}{
+		{"first version", "users-value", userSchemaV1, 12},
+		{"second version", "users-value", userSchemaV2, 15},
+		{"schema pinned under another subject", "members-value", userSchemaV1, 12},
+		{"schema not pinned under the subject", "members-value", userSchemaV2, 0},
+		{"schema not pinned", "users-value", userSchemaV3, 0},
+		{"unknown subject", "orders-value", userSchemaV1, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			created, createErr := client.CreateSchema(test.subject, test.schema, Avro)
+			found, lookupErr := client.LookupSchema(test.subject, test.schema, Avro)
+			if test.id == 0 {
+				assertNotFound(t, createErr)
+				assertNotFound(t, lookupErr)
+				return
+			}
+			if createErr != nil || lookupErr != nil {
+				t.Fatalf("cannot create or look up schema: %v, %v", createErr, lookupErr)
+			}
+			if created.ID() != test.id || found.ID() != test.id {
+				t.Errorf("created ID %d and found ID %d, want %d", created.ID(), found.ID(), test.id)
+			}
+		})
+	}
+}
+
+func TestBundleClientUnknownSchemas(t *testing.T) {
+	client := newTestBundle(t)
+	_, err := client.GetSchema(99)
+	assertNotFound(t, err)
+	_, err = client.GetLatestSchema("orders-value")
+	assertNotFound(t, err)
+	_, err = client.GetSchemaByVersion("users-value", 3)
+	assertNotFound(t, err)
+	_, err = client.TestCompatibility("orders-value", userSchemaV1, Avro)
+	assertNotFound(t, err)
+
+	compatible, err := client.TestCompatibility("users-value", userSchemaV3, Avro)
+	if err != nil || compatible {
+		t.Errorf("incompatible schema is compatible: %v, %v", compatible, err)
+	}
+	if err := client.SetCompatibilityLevel("users-value", None); err != ErrReadOnlyBundle {
+		t.Errorf("set compatibility level of a bundle: %v", err)
+	}
+	if _, err := client.DeleteSubject("users-value", false); err != ErrReadOnlyBundle {
+		t.Errorf("deleted subject of a bundle: %v", err)
+	}
+}
+
+func TestBundleClientInvalidLock(t *testing.T) {
+	tests := []struct {
+		name string
+		lock string
+	}{
+		{"missing id", `{"schemas": [{"subject": "users-value", "version": 1, "file": "users.avsc"}]}`},
+		{"missing file", `{"schemas": [{"subject": "users-value", "version": 1, "id": 12, "file": "missing.avsc"}]}`},
+		{"version pinned twice", `{"schemas": [
+			{"subject": "users-value", "version": 1, "id": 12, "file": "users.avsc"},
+			{"subject": "users-value", "version": 1, "id": 15, "file": "users_v2.avsc"}]}`},
+		{"id pinned to two schemas", `{"schemas": [
+			{"subject": "users-value", "version": 1, "id": 12, "file": "users.avsc"},
+			{"subject": "users-value", "version": 2, "id": 12, "file": "users_v2.avsc"}]}`},
+		{"not json", `schemas`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewBundleClient(fstest.MapFS{
+				"schemas.lock.json": {Data: []byte(test.lock)},
+				"users.avsc":        {Data: []byte(userSchemaV1)},
+				"users_v2.avsc":     {Data: []byte(userSchemaV2)},
+			}, "schemas.lock.json")
+			if err == nil {
+				t.Error("loaded an invalid bundle")
+			}
+		})
+	}
+}
@@ -0,0 +1,561 @@
+package schemaregistry
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Client allows interactions with
+// Schema Registry over HTTP. Applications using
+// this client can retrieve data about schemas,
+// which in turn can be used to Serialize and
+// Deserialize data.
+type Client struct {
+	schemaRegistryURLs     []string
+	urlIndex               uint32
+	maxRetries             int
+	retryBackoff           time.Duration
+	maxRetryBackoff        time.Duration
+	credentials            *credentials
+	bearerToken            func() (string, error)
+	httpClient             *http.Client
+	optionErr              error
+	cachingEnabled         bool
+	codecCreationEnabled   bool
+	idSchemaCache          map[int]*Schema
+	idSchemaCacheLock      sync.RWMutex
+	subjectSchemaCache     map[string]*Schema
+	subjectSchemaCacheLock sync.RWMutex
+}
+
+type credentials struct {
+	username string
+	password string
+}
+
+type schemaRequest struct {
+	Schema     string      `json:"schema"`
+	SchemaType string      `json:"schemaType,omitempty"`
+	References []Reference `json:"references,omitempty"`
+}
+
+type schemaResponse struct {
+	Subject    string      `json:"subject"`
+	Version    int         `json:"version"`
+	Schema     string      `json:"schema"`
+	SchemaType string      `json:"schemaType"`
+	References []Reference `json:"references"`
+	ID         int         `json:"id"`
+}
+
+type compatibilityResponse struct {
+	IsCompatible bool `json:"is_compatible"`
+}
+
+type configRequest struct {
+	Compatibility string `json:"compatibility"`
+}
+
+type configResponse struct {
+	CompatibilityLevel string `json:"compatibilityLevel"`
+}
+
+const (
+	schemaByID             = "/schemas/ids/%d"
+	subjectList            = "/subjects"
+	subjectByName          = "/subjects/%s"
+	subjectVersions        = "/subjects/%s/versions"
+	subjectByVersion       = "/subjects/%s/versions/%s"
+	compatibilityBySubject = "/compatibility/subjects/%s/versions/%s"
+	globalConfig           = "/config"
+	configBySubject        = "/config/%s"
+	contentType            = "application/vnd.schemaregistry.v1+json"
+)
+
+// NewClient creates a client that allows
+// interactions with Schema Registry over HTTP. Applications
+// using this client can retrieve data about schemas, which
+// in turn can be used to Serialize and Deserialize records.
+// Several comma-separated URLs can be given, in which case
+// requests fail over to the next URL when one is unavailable.
+func NewClient(schemaRegistryURL string, opts ...Option) *Client {
+	var urls []string
+	for _, url := range strings.Split(schemaRegistryURL, ",") {
+		urls = append(urls, strings.TrimRight(strings.TrimSpace(url), "/"))
+	}
+	client := &Client{schemaRegistryURLs: urls,
+		maxRetries: 3, retryBackoff: 100 * time.Millisecond, maxRetryBackoff: 2 * time.Second,
+		httpClient:     &http.Client{Timeout: 5 * time.Second},
+		cachingEnabled: true, codecCreationEnabled: true,
+		idSchemaCache:      make(map[int]*Schema),
+		subjectSchemaCache: make(map[string]*Schema)}
+	for _, opt := range opts {
+		opt(client)
+	}
+	return client
+}
+
+// GetSchema gets the schema associated with the given id.
+func (client *Client) GetSchema(schemaID int) (*Schema, error) {
+
+	if client.cachingEnabled {
+		client.idSchemaCacheLock.RLock()
+		cachedSchema := client.idSchemaCache[schemaID]
+		client.idSchemaCacheLock.RUnlock()
+		if cachedSchema != nil {
+			return cachedSchema, nil
+		}
+	}
+
+	resp, err := client.httpRequest("GET", fmt.Sprintf(schemaByID, schemaID), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var schemaResp = new(schemaResponse)
+	err = json.Unmarshal(resp, &schemaResp)
+	if err != nil {
+		return nil, err
+	}
+	schemaResp.ID = schemaID
+	schema, err := client.newSchema(schemaResp)
+	if err != nil {
+		return nil, err
+	}
+
+	if client.cachingEnabled {
+		client.idSchemaCacheLock.Lock()
+		client.idSchemaCache[schemaID] = schema
+		client.idSchemaCacheLock.Unlock()
+	}
+
+	return schema, nil
+}
+
+// GetLatestSchema gets the schema associated with the given subject.
+// The schema returned contains the last version for that subject.
+func (client *Client) GetLatestSchema(subject string) (*Schema, error) {
+
+	// In order to ensure consistency, the latest
+	// version is never read from the cache, which
+	// forces its retrieval from Schema Registry.
+	return client.getVersion(subject, "latest", false)
+}
+
+// GetSchemaVersions returns a list of versions from a given subject.
+func (client *Client) GetSchemaVersions(subject string) ([]int, error) {
+
+	resp, err := client.httpRequest("GET", fmt.Sprintf(subjectVersions, url.PathEscape(subject)), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions = []int{}
+	err = json.Unmarshal(resp, &versions)
+	if err != nil {
+		return nil, err
+	}
+
+	return versions, nil
+}
+
+// GetSchemaByVersion gets the schema associated with the given subject.
+// The schema returned contains the version specified as a parameter.
+func (client *Client) GetSchemaByVersion(subject string, version int) (*Schema, error) {
+	return client.getVersion(subject, strconv.Itoa(version), client.cachingEnabled)
+}
+
+// CreateSchema creates a new schema in Schema Registry and associates
+// with the subject provided. It returns the newly created schema with
+// all its associated information.
+func (client *Client) CreateSchema(subject string, schema string,
+	schemaType SchemaType, references ...Reference) (*Schema, error) {
+
+	payload, err := newSchemaPayload(schema, schemaType, references)
+	if err != nil {
+		return nil, err
+	}
+	_, err = client.httpRequest("POST", fmt.Sprintf(subjectVersions, url.PathEscape(subject)), payload)
+	if err != nil {
+		return nil, err
+	}
+
+	// Registration only answers with the ID of the schema,
+	// so the version it got under the subject is looked up.
+	// This logic strongly relies on the idempotent guarantees
+	// from Schema Registry, as well as in the best practice
+	// that schemas don't change very often.
+	return client.LookupSchema(subject, schema, schemaType, references...)
+}
+
+// TestCompatibility checks whether a schema is compatible with
+// the latest version registered under the subject, according
+// to the compatibility level configured for that subject.
+func (client *Client) TestCompatibility(subject string, schema string, schemaType SchemaType, references ...Reference) (bool, error) {
+	return client.testCompatibility(subject, "latest", schema, schemaType, references)
+}
+
+// TestCompatibilityByVersion checks whether a schema is compatible
+// with a specific version registered under the subject.
+func (client *Client) TestCompatibilityByVersion(subject string, version int, schema string, schemaType SchemaType, references ...Reference) (bool, error) {
+	return client.testCompatibility(subject, strconv.Itoa(version), schema, schemaType, references)
+}
+
+func (client *Client) testCompatibility(subject string, version string, schema string, schemaType SchemaType, references []Reference) (bool, error) {
+	payload, err := newSchemaPayload(schema, schemaType, references)
+	if err != nil {
+		return false, err
+	}
+	resp, err := client.httpRequest("POST", fmt.Sprintf(compatibilityBySubject, url.PathEscape(subject), version), payload)
+	if err != nil {
+		return false, err
+	}
+	compatibilityResp := new(compatibilityResponse)
+	err = json.Unmarshal(resp, &compatibilityResp)
+	if err != nil {
+		return false, err
+	}
+	return compatibilityResp.IsCompatible, nil
+}
+
+// GetCompatibilityLevel returns the compatibility level of the
+// subject, which is the global one unless it has been overridden.
+func (client *Client) GetCompatibilityLevel(subject string) (CompatibilityLevel, error) {
+	return client.getConfig(fmt.Sprintf(configBySubject, url.PathEscape(subject)) + "?defaultToGlobal=true")
+}
+
+// SetCompatibilityLevel overrides the compatibility level of the subject.
+func (client *Client) SetCompatibilityLevel(subject string, level CompatibilityLevel) error {
+	return client.setConfig(fmt.Sprintf(configBySubject, url.PathEscape(subject)), level)
+}
+
+// GetGlobalCompatibilityLevel returns the default compatibility
+// level of subjects that do not override it.
+func (client *Client) GetGlobalCompatibilityLevel() (CompatibilityLevel, error) {
+	return client.getConfig(globalConfig)
+}
+
+// SetGlobalCompatibilityLevel changes the default compatibility
+// level of subjects that do not override it.
+func (client *Client) SetGlobalCompatibilityLevel(level CompatibilityLevel) error {
+	return client.setConfig(globalConfig, level)
+}
+
+func (client *Client) getConfig(uri string) (CompatibilityLevel, error) {
+	resp, err := client.httpRequest("GET", uri, nil)
+	if err != nil {
+		return "", err
+	}
+	configResp := new(configResponse)
+	err = json.Unmarshal(resp, &configResp)
+	if err != nil {
+		return "", err
+	}
+	return CompatibilityLevel(configResp.CompatibilityLevel), nil
+}
+
+func (client *Client) setConfig(uri string, level CompatibilityLevel) error {
+	configBytes, err := json.Marshal(configRequest{Compatibility: level.String()})
+	if err != nil {
+		return err
+	}
+	_, err = client.httpRequest("PUT", uri, configBytes)
+	return err
+}
+
+// GetSubjects returns the subjects registered in Schema Registry.
+func (client *Client) GetSubjects() ([]string, error) {
+	resp, err := client.httpRequest("GET", subjectList, nil)
+	if err != nil {
+		return nil, err
+	}
+	var registeredSubjects = []string{}
+	err = json.Unmarshal(resp, &registeredSubjects)
+	if err != nil {
+		return nil, err
+	}
+	return registeredSubjects, nil
+}
+
+// DeleteSubject deletes every version of the subject and returns
+// the deleted versions. A soft delete keeps the schemas readable
+// by their IDs, while a permanent delete, which only applies to
+// subjects that were soft deleted before, removes them for good.
+func (client *Client) DeleteSubject(subject string, permanent bool) ([]int, error) {
+	uri := fmt.Sprintf(subjectByName, url.PathEscape(subject))
+	if permanent {
+		uri += "?permanent=true"
+	}
+	resp, err := client.httpRequest("DELETE", uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	var versions = []int{}
+	err = json.Unmarshal(resp, &versions)
+	if err != nil {
+		return nil, err
+	}
+	client.evictSubject(subject, versions)
+	return versions, nil
+}
+
+// DeleteSchemaVersion deletes a version of the subject, following
+// the same soft and permanent semantics as DeleteSubject.
+func (client *Client) DeleteSchemaVersion(subject string, version int, permanent bool) (int, error) {
+	uri := fmt.Sprintf(subjectByVersion, url.PathEscape(subject), strconv.Itoa(version))
+	if permanent {
+		uri += "?permanent=true"
+	}
+	resp, err := client.httpRequest("DELETE", uri, nil)
+	if err != nil {
+		return 0, err
+	}
+	var deletedVersion int
+	err = json.Unmarshal(resp, &deletedVersion)
+	if err != nil {
+		return 0, err
+	}
+	client.evictSubject(subject, []int{deletedVersion})
+	return deletedVersion, nil
+}
+
+// LookupSchema returns the schema registered under the subject
+// that matches the given one, without registering it.
+func (client *Client) LookupSchema(subject string, schema string, schemaType SchemaType, references ...Reference) (*Schema, error) {
+	payload, err := newSchemaPayload(schema, schemaType, references)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.httpRequest("POST", fmt.Sprintf(subjectByName, url.PathEscape(subject)), payload)
+	if err != nil {
+		return nil, err
+	}
+	schemaResp := new(schemaResponse)
+	err = json.Unmarshal(resp, &schemaResp)
+	if err != nil {
+		return nil, err
+	}
+	newSchema, err := client.newSchema(schemaResp)
+	if err != nil {
+		return nil, err
+	}
+	client.cacheSchema(subject, strconv.Itoa(newSchema.version), newSchema)
+	return newSchema, nil
+}
+
+func (client *Client) getVersion(subject string, version string, useCache bool) (*Schema, error) {
+
+	if useCache {
+		cacheKey := cacheKey(subject, version)
+		client.subjectSchemaCacheLock.RLock()
+		cachedResult := client.subjectSchemaCache[cacheKey]
+		client.subjectSchemaCacheLock.RUnlock()
+		if cachedResult != nil {
+			return cachedResult, nil
+		}
+	}
+
+	resp, err := client.httpRequest("GET", fmt.Sprintf(subjectByVersion, url.PathEscape(subject), version), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	schemaResp := new(schemaResponse)
+	err = json.Unmarshal(resp, &schemaResp)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := client.newSchema(schemaResp)
+	if err != nil {
+		return nil, err
+	}
+
+	client.cacheSchema(subject, version, schema)
+	return schema, nil
+}
+
+// cacheSchema updates the subject-2-schema and
+// id-2-schema caches when caching is enabled.
+func (client *Client) cacheSchema(subject string, version string, schema *Schema) {
+	if !client.cachingEnabled {
+		return
+	}
+
+	// Update the subject-2-schema cache
+	cacheKey := cacheKey(subject, version)
+	client.subjectSchemaCacheLock.Lock()
+	client.subjectSchemaCache[cacheKey] = schema
+	client.subjectSchemaCacheLock.Unlock()
+
+	// Update the id-2-schema cache
+	client.idSchemaCacheLock.Lock()
+	client.idSchemaCache[schema.id] = schema
+	client.idSchemaCacheLock.Unlock()
+}
+
+// evictSubject removes deleted versions of a subject from the
+// subject-2-schema cache. Schemas stay in the id-2-schema cache
+// because soft deleted schemas can still be read by their IDs.
+func (client *Client) evictSubject(subject string, versions []int) {
+	client.subjectSchemaCacheLock.Lock()
+	defer client.subjectSchemaCacheLock.Unlock()
+	delete(client.subjectSchemaCache, cacheKey(subject, "latest"))
+	for _, version := range versions {
+		delete(client.subjectSchemaCache, cacheKey(subject, strconv.Itoa(version)))
+	}
+}
+
+// newSchema builds a schema out of a Schema Registry response,
+// creating its codec when the schema is an Avro one.
+func (client *Client) newSchema(schemaResp *schemaResponse) (*Schema, error) {
+	return newSchema(schemaResp.ID, schemaResp.Subject, schemaResp.Version, schemaResp.Schema,
+		SchemaType(schemaResp.SchemaType), schemaResp.References, client.codecCreationEnabled)
+}
+
+func newSchemaPayload(schema string, schemaType SchemaType, references []Reference) ([]byte, error) {
+	schemaReq := schemaRequest{Schema: schema, References: references}
+	switch schemaType {
+	case Avro, Json:
+		compiledRegex := regexp.MustCompile(`\r?\n`)
+		schemaReq.Schema = compiledRegex.ReplaceAllString(schema, " ")
+	case Protobuf:
+		break
+	default:
+		return nil, fmt.Errorf("invalid schema type. valid values are Avro, Json, or Protobuf")
+	}
+	// Schema Registry assumes Avro when no type is given
+	if schemaType != Avro {
+		schemaReq.SchemaType = schemaType.String()
+	}
+	return json.Marshal(schemaReq)
+}
+
+// httpRequest sends a request to Schema Registry, moving on to the
+// next URL after each failed attempt. GET requests and POST requests,
+// which register, look up or check schemas, are retried with
+// exponential backoff on network errors, timeouts, throttling and
+// server errors, as registering a schema twice returns the ID of
+// the first one. Other requests, such as deletions whose retry
+// would fail once the first attempt succeeded, are not retried.
+func (client *Client) httpRequest(method, uri string, payload []byte) ([]byte, error) {
+	if client.optionErr != nil {
+		return nil, client.optionErr
+	}
+	idempotent := method == http.MethodGet || method == http.MethodPost
+	for attempt := 0; ; attempt++ {
+		index := atomic.LoadUint32(&client.urlIndex)
+		url := client.schemaRegistryURLs[int(index)%len(client.schemaRegistryURLs)]
+		resp, retryable, err := client.doHTTPRequest(method, url+uri, payload)
+		if err == nil {
+			return resp, nil
+		}
+		if !retryable || !idempotent || attempt >= client.maxRetries {
+			return nil, err
+		}
+		// Fail over unless a concurrent request already did
+		atomic.CompareAndSwapUint32(&client.urlIndex, index, index+1)
+		time.Sleep(client.retryDelay(attempt))
+	}
+}
+
+func (client *Client) doHTTPRequest(method, url string, payload []byte) ([]byte, bool, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, false, err
+	}
+	if client.credentials != nil {
+		req.SetBasicAuth(client.credentials.username, client.credentials.password)
+	}
+	if client.bearerToken != nil {
+		token, err := client.bearerToken()
+		if err != nil {
+			return nil, false, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	req.Header.Set("Content-Type", contentType)
+	resp, err := client.httpClient.Do(req)
+	if err != nil {
+		return nil, true, err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, isRetryableStatus(resp.StatusCode), createError(resp)
+	}
+
+	respBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, true, err
+	}
+	return respBytes, false, nil
+}
+
+// isRetryableStatus tells whether a request that failed with the
+// status code may succeed when sent again.
+func isRetryableStatus(statusCode int) bool {
+	return statusCode >= 500 || statusCode == http.StatusRequestTimeout || statusCode == http.StatusTooManyRequests
+}
+
+// retryDelay doubles the backoff on each attempt up to its
+// maximum, keeping a random half of it to spread out retries
+// of clients that failed at the same time.
+func (client *Client) retryDelay(attempt int) time.Duration {
+	delay := client.retryBackoff << uint(attempt)
+	if delay <= 0 || delay > client.maxRetryBackoff {
+		delay = client.maxRetryBackoff
+	}
+	half := int64(delay / 2)
+	return time.Duration(half + rand.Int63n(half+1))
+}
+
+func cacheKey(subject string, version string) string {
+	return fmt.Sprintf("%s-%s", subject, version)
+}
+
+// Error is returned when Schema Registry answers
+// a request with an unsuccessful status code.
+type Error struct {
+	StatusCode int
+	Status     string
+	ErrorCode  int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return e.Status
+	}
+	return fmt.Sprintf("%s: %s", e.Status, e.Message)
+}
+
+func createError(resp *http.Response) error {
+	decoder := json.NewDecoder(resp.Body)
+	var errorResp struct {
+		ErrorCode int    `json:"error_code"`
+		Message   string `json:"message"`
+	}
+	registryErr := &Error{StatusCode: resp.StatusCode, Status: resp.Status}
+	err := decoder.Decode(&errorResp)
+	if err == nil {
+		registryErr.ErrorCode = errorResp.ErrorCode
+		registryErr.Message = errorResp.Message
+	}
+	return registryErr
+}
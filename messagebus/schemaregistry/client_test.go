@@ -0,0 +1,99 @@
+package schemaregistry
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClientRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		request  func(client *Client) error
+		attempts int32
+	}{
+		{"get on server error", http.StatusServiceUnavailable, func(client *Client) error {
+			_, err := client.GetSubjects()
+			return err
+		}, 3},
+		{"get on throttling", http.StatusTooManyRequests, func(client *Client) error {
+			_, err := client.GetSubjects()
+			return err
+		}, 3},
+		{"lookup on timeout", http.StatusRequestTimeout, func(client *Client) error {
+			_, err := client.LookupSchema("users-value", userSchemaV1, Avro)
+			return err
+		}, 3},
+		{"get on client error", http.StatusNotFound, func(client *Client) error {
+			_, err := client.GetSubjects()
+			return err
+		}, 1},
+		{"delete on server error", http.StatusServiceUnavailable, func(client *Client) error {
+			_, err := client.DeleteSubject("users-value", false)
+			return err
+		}, 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var attempts int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&attempts, 1)
+				w.WriteHeader(test.status)
+			}))
+			defer server.Close()
+			client := NewClient(server.URL, WithRetries(2, time.Millisecond, time.Millisecond))
+			if err := test.request(client); err == nil {
+				t.Fatal("request succeeded")
+			}
+			if attempts != test.attempts {
+				t.Errorf("sent %d attempts, want %d", attempts, test.attempts)
+			}
+		})
+	}
+}
+
+// roundTripper sends requests with a function.
+type roundTripper func(request *http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(request *http.Request) (*http.Response, error) {
+	return f(request)
+}
+
+func TestClientTLSConfig(t *testing.T) {
+	var requests int32
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Write([]byte("[]"))
+	}))
+	defer server.Close()
+	tlsConfig := server.Client().Transport.(*http.Transport).TLSClientConfig
+	tests := []struct {
+		name       string
+		httpClient *http.Client
+		valid      bool
+	}{
+		{"default client", nil, true},
+		{"client with HTTP transport", &http.Client{Transport: &http.Transport{MaxIdleConns: 1}}, true},
+		{"client with other transport", &http.Client{Transport: roundTripper(http.DefaultTransport.RoundTrip)}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts := []Option{WithRetries(0, time.Millisecond, time.Millisecond)}
+			if test.httpClient != nil {
+				opts = append(opts, WithHTTPClient(test.httpClient))
+			}
+			atomic.StoreInt32(&requests, 0)
+			client := NewClient(server.URL, append(opts, WithTLSConfig(tlsConfig))...)
+			_, err := client.GetSubjects()
+			if test.valid != (err == nil) {
+				t.Errorf("error is %v, want an error: %v", err, !test.valid)
+			}
+			if !test.valid && atomic.LoadInt32(&requests) != 0 {
+				t.Error("sent a request without the TLS configuration")
+			}
+		})
+	}
+}
@@ -0,0 +1,238 @@
+package schemaregistry
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// NewFakeServer starts an HTTP server speaking the Schema Registry
+// REST API on top of registry, which is usually a MockClient, so
+// that code configured with a Schema Registry URL runs offline.
+// The server must be closed once the test is done.
+// Example:
+// 		server := schemaregistry.NewFakeServer(schemaregistry.NewMockClient())
+// 		defer server.Close()
+// 		serializer, err := messagebus.NewSerializer(server.URL, messagebus.TOPIC_NAME_STRATEGY)
+func NewFakeServer(registry IClient) *httptest.Server {
+	return httptest.NewServer(fakeServer{registry: registry})
+}
+
+type fakeServer struct {
+	registry IClient
+}
+
+type idResponse struct {
+	ID int `json:"id"`
+}
+
+type schemaByIDResponse struct {
+	Schema     string      `json:"schema"`
+	SchemaType string      `json:"schemaType,omitempty"`
+	References []Reference `json:"references,omitempty"`
+}
+
+type errorResponse struct {
+	ErrorCode int    `json:"error_code"`
+	Message   string `json:"message"`
+}
+
+func (s fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	response, err := s.route(r)
+	if err != nil {
+		var registryErr *Error
+		if !errors.As(err, &registryErr) {
+			registryErr = &Error{StatusCode: http.StatusInternalServerError, ErrorCode: 50001, Message: err.Error()}
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(registryErr.StatusCode)
+		_ = json.NewEncoder(w).Encode(errorResponse{ErrorCode: registryErr.ErrorCode, Message: registryErr.Message})
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+func (s fakeServer) route(r *http.Request) (interface{}, error) {
+	// Subjects are escaped, so the path is split before unescaping
+	path := strings.Split(strings.Trim(r.URL.EscapedPath(), "/"), "/")
+	for i, segment := range path {
+		unescaped, err := url.PathUnescape(segment)
+		if err != nil {
+			return nil, notFoundError(404, "Invalid path segment %s", segment)
+		}
+		path[i] = unescaped
+	}
+	permanent := r.URL.Query().Get("permanent") == "true"
+	switch {
+	case len(path) == 3 && path[0] == "schemas" && path[1] == "ids" && r.Method == http.MethodGet:
+		id, err := strconv.Atoi(path[2])
+		if err != nil {
+			return nil, notFoundError(40403, "Schema %s not found", path[2])
+		}
+		schema, err := s.registry.GetSchema(id)
+		if err != nil {
+			return nil, err
+		}
+		return newSchemaByIDResponse(schema), nil
+
+	case len(path) == 1 && path[0] == "subjects" && r.Method == http.MethodGet:
+		return s.registry.GetSubjects()
+
+	case len(path) == 2 && path[0] == "subjects":
+		switch r.Method {
+		case http.MethodPost:
+			req, err := decodeSchemaRequest(r)
+			if err != nil {
+				return nil, err
+			}
+			schema, err := s.registry.LookupSchema(path[1], req.Schema, SchemaType(req.SchemaType), req.References...)
+			if err != nil {
+				return nil, err
+			}
+			return newSchemaResponse(schema), nil
+		case http.MethodDelete:
+			return s.registry.DeleteSubject(path[1], permanent)
+		}
+
+	case len(path) == 3 && path[0] == "subjects" && path[2] == "versions":
+		switch r.Method {
+		case http.MethodGet:
+			return s.registry.GetSchemaVersions(path[1])
+		case http.MethodPost:
+			req, err := decodeSchemaRequest(r)
+			if err != nil {
+				return nil, err
+			}
+			schema, err := s.registry.CreateSchema(path[1], req.Schema, SchemaType(req.SchemaType), req.References...)
+			if err != nil {
+				return nil, err
+			}
+			return idResponse{ID: schema.ID()}, nil
+		}
+
+	case len(path) == 4 && path[0] == "subjects" && path[2] == "versions":
+		switch r.Method {
+		case http.MethodGet:
+			var schema *Schema
+			var err error
+			if path[3] == "latest" {
+				schema, err = s.registry.GetLatestSchema(path[1])
+			} else {
+				var version int
+				version, err = parseVersion(path[3])
+				if err == nil {
+					schema, err = s.registry.GetSchemaByVersion(path[1], version)
+				}
+			}
+			if err != nil {
+				return nil, err
+			}
+			return newSchemaResponse(schema), nil
+		case http.MethodDelete:
+			version, err := parseVersion(path[3])
+			if err != nil {
+				return nil, err
+			}
+			return s.registry.DeleteSchemaVersion(path[1], version, permanent)
+		}
+
+	case len(path) == 5 && path[0] == "compatibility" && path[1] == "subjects" && path[3] == "versions" && r.Method == http.MethodPost:
+		req, err := decodeSchemaRequest(r)
+		if err != nil {
+			return nil, err
+		}
+		var compatible bool
+		if path[4] == "latest" {
+			compatible, err = s.registry.TestCompatibility(path[2], req.Schema, SchemaType(req.SchemaType), req.References...)
+		} else {
+			var version int
+			version, err = parseVersion(path[4])
+			if err == nil {
+				compatible, err = s.registry.TestCompatibilityByVersion(path[2], version, req.Schema, SchemaType(req.SchemaType), req.References...)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		return compatibilityResponse{IsCompatible: compatible}, nil
+
+	case (len(path) == 1 || len(path) == 2) && path[0] == "config":
+		switch r.Method {
+		case http.MethodGet:
+			var level CompatibilityLevel
+			var err error
+			if len(path) == 1 {
+				level, err = s.registry.GetGlobalCompatibilityLevel()
+			} else {
+				level, err = s.registry.GetCompatibilityLevel(path[1])
+			}
+			if err != nil {
+				return nil, err
+			}
+			return configResponse{CompatibilityLevel: level.String()}, nil
+		case http.MethodPut:
+			var req configRequest
+			err := json.NewDecoder(r.Body).Decode(&req)
+			if err != nil {
+				return nil, unprocessableError(err)
+			}
+			level := CompatibilityLevel(req.Compatibility)
+			if len(path) == 1 {
+				err = s.registry.SetGlobalCompatibilityLevel(level)
+			} else {
+				err = s.registry.SetCompatibilityLevel(path[1], level)
+			}
+			if err != nil {
+				return nil, err
+			}
+			return req, nil
+		}
+	}
+	return nil, &Error{StatusCode: http.StatusNotFound, ErrorCode: 404, Message: "HTTP 404 Not Found"}
+}
+
+func decodeSchemaRequest(r *http.Request) (*schemaRequest, error) {
+	req := new(schemaRequest)
+	err := json.NewDecoder(r.Body).Decode(req)
+	if err != nil {
+		return nil, unprocessableError(err)
+	}
+	return req, nil
+}
+
+func parseVersion(version string) (int, error) {
+	number, err := strconv.Atoi(version)
+	if err != nil || number <= 0 {
+		return 0, &Error{StatusCode: http.StatusUnprocessableEntity, ErrorCode: 42202,
+			Message: "The specified version '" + version + "' is not a valid version id."}
+	}
+	return number, nil
+}
+
+func newSchemaResponse(schema *Schema) schemaResponse {
+	return schemaResponse{
+		Subject:    schema.Subject(),
+		Version:    schema.Version(),
+		Schema:     schema.Schema(),
+		SchemaType: schema.SchemaType().String(),
+		References: schema.References(),
+		ID:         schema.ID(),
+	}
+}
+
+func newSchemaByIDResponse(schema *Schema) schemaByIDResponse {
+	response := schemaByIDResponse{Schema: schema.Schema(), References: schema.References()}
+	if schema.SchemaType() != Avro {
+		response.SchemaType = schema.SchemaType().String()
+	}
+	return response
+}
+
+func unprocessableError(err error) error {
+	return &Error{StatusCode: http.StatusUnprocessableEntity, ErrorCode: 422, Message: err.Error()}
+}
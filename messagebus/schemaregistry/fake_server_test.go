@@ -0,0 +1,155 @@
+package schemaregistry
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+const (
+	userSchemaV1 = `{"type": "record", "name": "User", "fields": [{"name": "name", "type": "string"}]}`
+	userSchemaV2 = `{"type": "record", "name": "User", "fields": [{"name": "name", "type": "string"}, {"name": "age", "type": "int", "default": 0}]}`
+	userSchemaV3 = `{"type": "record", "name": "User", "fields": [{"name": "name", "type": "string"}, {"name": "email", "type": "string"}]}`
+)
+
+func newFakeServerClient(t *testing.T) *Client {
+	server := NewFakeServer(NewMockClient())
+	t.Cleanup(server.Close)
+	return NewClient(server.URL, WithRetries(0, 0, 0))
+}
+
+func TestFakeServerRegistration(t *testing.T) {
+	client := newFakeServerClient(t)
+
+	first, err := client.CreateSchema("users-value", userSchemaV1, Avro)
+	if err != nil {
+		t.Fatalf("cannot register schema: %v", err)
+	}
+	again, err := client.CreateSchema("users-value", userSchemaV1, Avro)
+	if err != nil {
+		t.Fatalf("cannot register schema again: %v", err)
+	}
+	if again.ID() != first.ID() || again.Version() != 1 {
+		t.Errorf("registering the same schema got id %d version %d, want id %d version 1", again.ID(), again.Version(), first.ID())
+	}
+
+	second, err := client.CreateSchema("users-value", userSchemaV2, Avro)
+	if err != nil {
+		t.Fatalf("cannot register second version: %v", err)
+	}
+	if second.Version() != 2 || second.ID() == first.ID() {
+		t.Errorf("second version got id %d version %d", second.ID(), second.Version())
+	}
+
+	byID, err := client.GetSchema(first.ID())
+	if err != nil {
+		t.Fatalf("cannot get schema by id: %v", err)
+	}
+	if byID.Codec() == nil {
+		t.Error("schema by id has no codec")
+	}
+	latest, err := client.GetLatestSchema("users-value")
+	if err != nil || latest.Version() != 2 {
+		t.Fatalf("latest schema is %v, %v", latest, err)
+	}
+	versions, err := client.GetSchemaVersions("users-value")
+	if err != nil || len(versions) != 2 {
+		t.Fatalf("versions are %v, %v", versions, err)
+	}
+	subjects, err := client.GetSubjects()
+	if err != nil || len(subjects) != 1 || subjects[0] != "users-value" {
+		t.Fatalf("subjects are %v, %v", subjects, err)
+	}
+
+	deleted, err := client.DeleteSchemaVersion("users-value", 2, false)
+	if err != nil || deleted != 2 {
+		t.Fatalf("deleted version %d, %v", deleted, err)
+	}
+	_, err = client.GetSchemaByVersion("users-value", 2)
+	var registryErr *Error
+	if !errors.As(err, &registryErr) || registryErr.StatusCode != http.StatusNotFound {
+		t.Errorf("getting a deleted version returned %v, want not found", err)
+	}
+}
+
+func TestFakeServerCompatibility(t *testing.T) {
+	client := newFakeServerClient(t)
+	_, err := client.TestCompatibility("users-value", userSchemaV1, Avro)
+	var registryErr *Error
+	if !errors.As(err, &registryErr) || registryErr.StatusCode != http.StatusNotFound {
+		t.Errorf("testing the compatibility with an unknown subject returned %v, want not found", err)
+	}
+	_, err = client.CreateSchema("users-value", userSchemaV1, Avro)
+	if err != nil {
+		t.Fatalf("cannot register schema: %v", err)
+	}
+	err = client.SetCompatibilityLevel("users-value", Backward)
+	if err != nil {
+		t.Fatalf("cannot set compatibility level: %v", err)
+	}
+	level, err := client.GetCompatibilityLevel("users-value")
+	if err != nil || level != Backward {
+		t.Fatalf("compatibility level is %v, %v", level, err)
+	}
+
+	tests := []struct {
+		name       string
+		schema     string
+		compatible bool
+	}{
+		{"field with default", userSchemaV2, true},
+		{"field without default", userSchemaV3, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			compatible, err := client.TestCompatibility("users-value", test.schema, Avro)
+			if err != nil {
+				t.Fatalf("cannot test compatibility: %v", err)
+			}
+			if compatible != test.compatible {
+				t.Errorf("compatible = %v, want %v", compatible, test.compatible)
+			}
+		})
+	}
+
+	_, err = client.CreateSchema("users-value", userSchemaV3, Avro)
+	if !errors.As(err, &registryErr) || registryErr.StatusCode != http.StatusConflict {
+		t.Errorf("registering an incompatible schema returned %v, want conflict", err)
+	}
+}
+
+func TestFakeServerEscapedSubjects(t *testing.T) {
+	client := newFakeServerClient(t)
+	subjects := []string{"common/money.proto", "what?", "hash#tag", "100%", "a b"}
+	for _, subject := range subjects {
+		t.Run(subject, func(t *testing.T) {
+			created, err := client.CreateSchema(subject, userSchemaV1, Avro)
+			if err != nil {
+				t.Fatalf("cannot register schema: %v", err)
+			}
+			found, err := client.LookupSchema(subject, userSchemaV1, Avro)
+			if err != nil {
+				t.Fatalf("cannot look schema up: %v", err)
+			}
+			if found.Subject() != subject || found.ID() != created.ID() {
+				t.Errorf("looked up subject %q id %d, want %q id %d", found.Subject(), found.ID(), subject, created.ID())
+			}
+			latest, err := client.GetLatestSchema(subject)
+			if err != nil || latest.Subject() != subject {
+				t.Fatalf("latest schema is %v, %v", latest, err)
+			}
+			compatible, err := client.TestCompatibility(subject, userSchemaV2, Avro)
+			if err != nil || !compatible {
+				t.Errorf("compatibility is %v, %v", compatible, err)
+			}
+			err = client.SetCompatibilityLevel(subject, Full)
+			if err != nil {
+				t.Fatalf("cannot set compatibility level: %v", err)
+			}
+			versions, err := client.DeleteSubject(subject, false)
+			if err != nil || len(versions) != 1 {
+				t.Errorf("deleted versions %v, %v", versions, err)
+			}
+		})
+	}
+}
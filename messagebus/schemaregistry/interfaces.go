@@ -0,0 +1,21 @@
+package schemaregistry
+
+// IClient is implemented by Client, and by anything else
+// that can stand in for Schema Registry.
+type IClient interface {
+	GetSchema(schemaID int) (*Schema, error)
+	GetLatestSchema(subject string) (*Schema, error)
+	GetSchemaVersions(subject string) ([]int, error)
+	GetSchemaByVersion(subject string, version int) (*Schema, error)
+	CreateSchema(subject string, schema string, schemaType SchemaType, references ...Reference) (*Schema, error)
+	LookupSchema(subject string, schema string, schemaType SchemaType, references ...Reference) (*Schema, error)
+	TestCompatibility(subject string, schema string, schemaType SchemaType, references ...Reference) (bool, error)
+	TestCompatibilityByVersion(subject string, version int, schema string, schemaType SchemaType, references ...Reference) (bool, error)
+	GetCompatibilityLevel(subject string) (CompatibilityLevel, error)
+	SetCompatibilityLevel(subject string, level CompatibilityLevel) error
+	GetGlobalCompatibilityLevel() (CompatibilityLevel, error)
+	SetGlobalCompatibilityLevel(level CompatibilityLevel) error
+	GetSubjects() ([]string, error)
+	DeleteSubject(subject string, permanent bool) ([]int, error)
+	DeleteSchemaVersion(subject string, version int, permanent bool) (int, error)
+}
@@ -0,0 +1,461 @@
+package schemaregistry
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/kata-ai/messagebus-golang-kafka/messagebus/internal/avro"
+	"github.com/linkedin/goavro/v2"
+)
+
+// MockClient is an in-memory IClient for tests that must run
+// without Schema Registry. It assigns IDs, tracks the versions
+// of each subject and enforces compatibility levels the way
+// Schema Registry does, answering failures with the same
+// errors. Compatibility is only checked for Avro schemas.
+type MockClient struct {
+	lock        sync.RWMutex
+	nextID      int
+	ids         map[int]*Schema
+	subjects    map[string][]*mockVersion
+	globalLevel CompatibilityLevel
+	levels      map[string]CompatibilityLevel
+}
+
+type mockVersion struct {
+	schema  *Schema
+	deleted bool
+}
+
+// NewMockClient creates an empty in-memory registry with
+// the BACKWARD compatibility level, as Schema Registry has.
+func NewMockClient() *MockClient {
+	return &MockClient{
+		nextID:      1,
+		ids:         make(map[int]*Schema),
+		subjects:    make(map[string][]*mockVersion),
+		globalLevel: Backward,
+		levels:      make(map[string]CompatibilityLevel),
+	}
+}
+
+// GetSchema gets the schema associated with the given id.
+func (client *MockClient) GetSchema(schemaID int) (*Schema, error) {
+	client.lock.RLock()
+	defer client.lock.RUnlock()
+	schema, ok := client.ids[schemaID]
+	if !ok {
+		return nil, notFoundError(40403, "Schema %d not found", schemaID)
+	}
+	return schema, nil
+}
+
+// GetLatestSchema gets the last version registered under the subject.
+func (client *MockClient) GetLatestSchema(subject string) (*Schema, error) {
+	client.lock.RLock()
+	defer client.lock.RUnlock()
+	versions, err := client.liveVersions(subject)
+	if err != nil {
+		return nil, err
+	}
+	return versions[len(versions)-1].schema, nil
+}
+
+// GetSchemaVersions returns a list of versions from a given subject.
+func (client *MockClient) GetSchemaVersions(subject string) ([]int, error) {
+	client.lock.RLock()
+	defer client.lock.RUnlock()
+	versions, err := client.liveVersions(subject)
+	if err != nil {
+		return nil, err
+	}
+	var numbers []int
+	for _, version := range versions {
+		numbers = append(numbers, version.schema.version)
+	}
+	return numbers, nil
+}
+
+// GetSchemaByVersion gets a version registered under the subject.
+func (client *MockClient) GetSchemaByVersion(subject string, version int) (*Schema, error) {
+	client.lock.RLock()
+	defer client.lock.RUnlock()
+	found, err := client.findVersion(subject, version)
+	if err != nil {
+		return nil, err
+	}
+	return found.schema, nil
+}
+
+// CreateSchema registers the schema under the subject unless it is
+// registered already, failing when it is incompatible with the
+// versions the compatibility level of the subject checks against.
+// A schema registered under several subjects keeps a single ID.
+func (client *MockClient) CreateSchema(subject string, schema string,
+	schemaType SchemaType, references ...Reference) (*Schema, error) {
+
+	if schemaType == "" {
+		schemaType = Avro
+	}
+	canonical, err := canonicalSchema(schema, schemaType)
+	if err != nil {
+		return nil, err
+	}
+
+	client.lock.Lock()
+	defer client.lock.Unlock()
+	versions, _ := client.liveVersions(subject)
+	for _, version := range versions {
+		if schemaMatches(version.schema, canonical, schemaType, references) {
+			return version.schema, nil
+		}
+	}
+	err = checkCompatibility(client.levelOf(subject), schema, schemaType, schemasOf(versions))
+	if isIncompatible(err) {
+		return nil, &Error{StatusCode: http.StatusConflict, Status: "409 Conflict", ErrorCode: 409,
+			Message: fmt.Sprintf("Schema being registered is incompatible with an earlier schema for subject \"%s\": %v", subject, err)}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	id := client.nextID
+	for existingID, existing := range client.ids {
+		if schemaMatches(existing, canonical, schemaType, references) {
+			id = existingID
+		}
+	}
+	if id == client.nextID {
+		byID, err := NewSchema(id, "", 0, schema, schemaType, references)
+		if err != nil {
+			return nil, invalidSchemaError(err)
+		}
+		client.ids[id] = byID
+		client.nextID++
+	}
+
+	number := 1
+	if all := client.subjects[subject]; len(all) > 0 {
+		number = all[len(all)-1].schema.version + 1
+	}
+	registered, err := NewSchema(id, subject, number, schema, schemaType, references)
+	if err != nil {
+		return nil, invalidSchemaError(err)
+	}
+	client.subjects[subject] = append(client.subjects[subject], &mockVersion{schema: registered})
+	return registered, nil
+}
+
+// LookupSchema returns the version of the subject matching the schema.
+func (client *MockClient) LookupSchema(subject string, schema string, schemaType SchemaType, references ...Reference) (*Schema, error) {
+	if schemaType == "" {
+		schemaType = Avro
+	}
+	canonical, err := canonicalSchema(schema, schemaType)
+	if err != nil {
+		return nil, err
+	}
+	client.lock.RLock()
+	defer client.lock.RUnlock()
+	versions, err := client.liveVersions(subject)
+	if err != nil {
+		return nil, err
+	}
+	for _, version := range versions {
+		if schemaMatches(version.schema, canonical, schemaType, references) {
+			return version.schema, nil
+		}
+	}
+	return nil, notFoundError(40403, "Schema not found")
+}
+
+// TestCompatibility checks the schema against the latest version of
+// the subject, or against every version for transitive levels. Like
+// Schema Registry, it fails with a 404 for unknown subjects.
+func (client *MockClient) TestCompatibility(subject string, schema string, schemaType SchemaType, references ...Reference) (bool, error) {
+	client.lock.RLock()
+	defer client.lock.RUnlock()
+	versions, err := client.liveVersions(subject)
+	if err != nil {
+		return false, err
+	}
+	return client.testCompatibility(subject, schema, schemaType, versions)
+}
+
+// TestCompatibilityByVersion checks the schema against a version of the subject.
+func (client *MockClient) TestCompatibilityByVersion(subject string, version int, schema string, schemaType SchemaType, references ...Reference) (bool, error) {
+	client.lock.RLock()
+	defer client.lock.RUnlock()
+	found, err := client.findVersion(subject, version)
+	if err != nil {
+		return false, err
+	}
+	return client.testCompatibility(subject, schema, schemaType, []*mockVersion{found})
+}
+
+func (client *MockClient) testCompatibility(subject string, schema string, schemaType SchemaType, versions []*mockVersion) (bool, error) {
+	if schemaType == "" {
+		schemaType = Avro
+	}
+	if _, err := canonicalSchema(schema, schemaType); err != nil {
+		return false, err
+	}
+	err := checkCompatibility(client.levelOf(subject), schema, schemaType, schemasOf(versions))
+	if isIncompatible(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// GetCompatibilityLevel returns the level of the subject, which is
+// the global one unless it has been overridden.
+func (client *MockClient) GetCompatibilityLevel(subject string) (CompatibilityLevel, error) {
+	client.lock.RLock()
+	defer client.lock.RUnlock()
+	return client.levelOf(subject), nil
+}
+
+// SetCompatibilityLevel overrides the compatibility level of the subject.
+func (client *MockClient) SetCompatibilityLevel(subject string, level CompatibilityLevel) error {
+	if err := validateLevel(level); err != nil {
+		return err
+	}
+	client.lock.Lock()
+	defer client.lock.Unlock()
+	client.levels[subject] = level
+	return nil
+}
+
+// GetGlobalCompatibilityLevel returns the default compatibility level.
+func (client *MockClient) GetGlobalCompatibilityLevel() (CompatibilityLevel, error) {
+	client.lock.RLock()
+	defer client.lock.RUnlock()
+	return client.globalLevel, nil
+}
+
+// SetGlobalCompatibilityLevel changes the default compatibility level.
+func (client *MockClient) SetGlobalCompatibilityLevel(level CompatibilityLevel) error {
+	if err := validateLevel(level); err != nil {
+		return err
+	}
+	client.lock.Lock()
+	defer client.lock.Unlock()
+	client.globalLevel = level
+	return nil
+}
+
+// GetSubjects returns the subjects that have live versions.
+func (client *MockClient) GetSubjects() ([]string, error) {
+	client.lock.RLock()
+	defer client.lock.RUnlock()
+	subjects := []string{}
+	for subject := range client.subjects {
+		if _, err := client.liveVersions(subject); err == nil {
+			subjects = append(subjects, subject)
+		}
+	}
+	sort.Strings(subjects)
+	return subjects, nil
+}
+
+// DeleteSubject soft deletes every version of the subject, or
+// removes them for good when they were soft deleted before.
+func (client *MockClient) DeleteSubject(subject string, permanent bool) ([]int, error) {
+	client.lock.Lock()
+	defer client.lock.Unlock()
+	all := client.subjects[subject]
+	if len(all) == 0 {
+		return nil, notFoundError(40401, "Subject '%s' not found.", subject)
+	}
+	var deleted []int
+	if permanent {
+		for _, version := range all {
+			if !version.deleted {
+				return nil, notFoundError(40405, "Subject '%s' was not deleted first before being permanently deleted", subject)
+			}
+			deleted = append(deleted, version.schema.version)
+		}
+		delete(client.subjects, subject)
+		delete(client.levels, subject)
+		return deleted, nil
+	}
+	for _, version := range all {
+		if !version.deleted {
+			version.deleted = true
+			deleted = append(deleted, version.schema.version)
+		}
+	}
+	if len(deleted) == 0 {
+		return nil, notFoundError(40401, "Subject '%s' not found.", subject)
+	}
+	return deleted, nil
+}
+
+// DeleteSchemaVersion soft deletes a version of the subject, or
+// removes it for good when it was soft deleted before.
+func (client *MockClient) DeleteSchemaVersion(subject string, version int, permanent bool) (int, error) {
+	client.lock.Lock()
+	defer client.lock.Unlock()
+	all := client.subjects[subject]
+	if len(all) == 0 {
+		return 0, notFoundError(40401, "Subject '%s' not found.", subject)
+	}
+	for i, found := range all {
+		if found.schema.version != version {
+			continue
+		}
+		if permanent {
+			if !found.deleted {
+				return 0, notFoundError(40407, "Subject '%s' Version %d was not deleted first before being permanently deleted", subject, version)
+			}
+			client.subjects[subject] = append(all[:i:i], all[i+1:]...)
+			return version, nil
+		}
+		if found.deleted {
+			break
+		}
+		found.deleted = true
+		return version, nil
+	}
+	return 0, notFoundError(40402, "Version %d not found.", version)
+}
+
+// liveVersions returns the versions of the subject that are
+// not deleted, failing when there are none.
+func (client *MockClient) liveVersions(subject string) ([]*mockVersion, error) {
+	var versions []*mockVersion
+	for _, version := range client.subjects[subject] {
+		if !version.deleted {
+			versions = append(versions, version)
+		}
+	}
+	if len(versions) == 0 {
+		return nil, notFoundError(40401, "Subject '%s' not found.", subject)
+	}
+	return versions, nil
+}
+
+func (client *MockClient) findVersion(subject string, version int) (*mockVersion, error) {
+	versions, err := client.liveVersions(subject)
+	if err != nil {
+		return nil, err
+	}
+	for _, found := range versions {
+		if found.schema.version == version {
+			return found, nil
+		}
+	}
+	return nil, notFoundError(40402, "Version %d not found.", version)
+}
+
+func schemasOf(versions []*mockVersion) []*Schema {
+	var schemas []*Schema
+	for _, version := range versions {
+		schemas = append(schemas, version.schema)
+	}
+	return schemas
+}
+
+func (client *MockClient) levelOf(subject string) CompatibilityLevel {
+	if level, ok := client.levels[subject]; ok {
+		return level
+	}
+	return client.globalLevel
+}
+
+func schemaMatches(schema *Schema, canonical string, schemaType SchemaType, references []Reference) bool {
+	if schema.schemaType != schemaType || len(schema.references) != len(references) {
+		return false
+	}
+	if len(references) > 0 && !reflect.DeepEqual(schema.references, references) {
+		return false
+	}
+	existing, err := canonicalSchema(schema.schema, schema.schemaType)
+	return err == nil && existing == canonical
+}
+
+// canonicalSchema returns the form schemas are compared with,
+// which is the Parsing Canonical Form for Avro schemas.
+func canonicalSchema(schema string, schemaType SchemaType) (string, error) {
+	switch schemaType {
+	case Avro:
+		codec, err := goavro.NewCodec(schema)
+		if err != nil {
+			return "", invalidSchemaError(err)
+		}
+		return codec.CanonicalSchema(), nil
+	case Json, Protobuf:
+		return schema, nil
+	default:
+		return "", &Error{StatusCode: http.StatusUnprocessableEntity, Status: "422 Unprocessable Entity",
+			ErrorCode: 422, Message: fmt.Sprintf("Invalid schema type %s", schemaType)}
+	}
+}
+
+// incompatibleError marks schemas that break the compatibility
+// level, which registration reports as a conflict and compatibility
+// tests report as an incompatible result.
+type incompatibleError struct {
+	err error
+}
+
+func (e incompatibleError) Error() string {
+	return e.err.Error()
+}
+
+func isIncompatible(err error) bool {
+	_, ok := err.(incompatibleError)
+	return ok
+}
+
+// checkCompatibility checks an Avro schema against the previous
+// versions of its subject, oldest first, according to the level.
+// Backward compatible schemas can read data written with the
+// previous versions, and forward compatible ones can be read by them.
+func checkCompatibility(level CompatibilityLevel, schema string, schemaType SchemaType, previous []*Schema) error {
+	if schemaType != Avro || level == None || len(previous) == 0 {
+		return nil
+	}
+	switch level {
+	case Backward, Forward, Full:
+		previous = previous[len(previous)-1:]
+	}
+	for _, version := range previous {
+		if version.schemaType != Avro {
+			continue
+		}
+		if level != Forward && level != ForwardTransitive {
+			if _, err := avro.Resolve(version.schema, schema); err != nil {
+				return incompatibleError{fmt.Errorf("schema cannot read version %d: %v", version.version, err)}
+			}
+		}
+		if level != Backward && level != BackwardTransitive {
+			if _, err := avro.Resolve(schema, version.schema); err != nil {
+				return incompatibleError{fmt.Errorf("version %d cannot read schema: %v", version.version, err)}
+			}
+		}
+	}
+	return nil
+}
+
+func validateLevel(level CompatibilityLevel) error {
+	switch level {
+	case None, Backward, BackwardTransitive, Forward, ForwardTransitive, Full, FullTransitive:
+		return nil
+	}
+	return &Error{StatusCode: http.StatusUnprocessableEntity, Status: "422 Unprocessable Entity",
+		ErrorCode: 42203, Message: fmt.Sprintf("Invalid compatibility level %s", level)}
+}
+
+func notFoundError(errorCode int, format string, args ...interface{}) error {
+	return &Error{StatusCode: http.StatusNotFound, Status: "404 Not Found",
+		ErrorCode: errorCode, Message: fmt.Sprintf(format, args...)}
+}
+
+func invalidSchemaError(err error) error {
+	return &Error{StatusCode: http.StatusUnprocessableEntity, Status: "422 Unprocessable Entity",
+		ErrorCode: 42201, Message: fmt.Sprintf("Invalid schema: %v", err)}
+}
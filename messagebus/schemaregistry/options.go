@@ -0,0 +1,142 @@
+package schemaregistry
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// Option configures how the client reaches Schema Registry.
+// Options are applied in order, so a custom HTTP client
+// should come before the options that adjust it.
+type Option func(client *Client)
+
+// Authenticate to Schema Registry with HTTP basic auth
+func WithBasicAuth(username string, password string) Option {
+	return func(client *Client) {
+		if len(username) > 0 && len(password) > 0 {
+			client.credentials = &credentials{username, password}
+		}
+	}
+}
+
+// Authenticate to Schema Registry with a static bearer token
+func WithBearerToken(token string) Option {
+	return WithBearerTokenSource(func() (string, error) {
+		return token, nil
+	})
+}
+
+// Authenticate to Schema Registry with a bearer token fetched before
+// every request, for tokens that expire and need to be refreshed
+func WithBearerTokenSource(tokenSource func() (string, error)) Option {
+	return func(client *Client) {
+		client.bearerToken = tokenSource
+	}
+}
+
+// Configure TLS for Schema Registry connections, such as client
+// certificates for mutual TLS or a private certificate authority.
+// A custom HTTP client must use an *http.Transport, or have no
+// transport, as other transports cannot be given a TLS configuration.
+// Requests of the client fail otherwise.
+func WithTLSConfig(config *tls.Config) Option {
+	return func(client *Client) {
+		httpClient := client.copyHTTPClient()
+		var transport *http.Transport
+		switch current := httpClient.Transport.(type) {
+		case nil:
+			transport = http.DefaultTransport.(*http.Transport).Clone()
+		case *http.Transport:
+			transport = current.Clone()
+		default:
+			client.optionErr = fmt.Errorf("cannot configure TLS of HTTP transport %T", current)
+			return
+		}
+		transport.TLSClientConfig = config
+		httpClient.Transport = transport
+	}
+}
+
+// Use a custom HTTP client for Schema Registry requests
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(client *Client) {
+		client.httpClient = httpClient
+	}
+}
+
+// Configure timeout of Schema Registry requests, which defaults to five seconds
+func WithTimeout(timeout time.Duration) Option {
+	return func(client *Client) {
+		client.copyHTTPClient().Timeout = timeout
+	}
+}
+
+// copyHTTPClient replaces the HTTP client with a copy before an
+// option adjusts it, so that a client given with WithHTTPClient,
+// such as http.DefaultClient, is left as is.
+func (client *Client) copyHTTPClient() *http.Client {
+	httpClient := *client.httpClient
+	client.httpClient = &httpClient
+	return client.httpClient
+}
+
+// Configure how many times failed Schema Registry requests are retried,
+// which defaults to three, along with the initial and maximum backoff
+// between attempts, which default to 100 milliseconds and two seconds.
+// Only GET and POST requests failing with network errors, timeouts,
+// throttling or server errors are retried.
+func WithRetries(maxRetries int, backoff time.Duration, maxBackoff time.Duration) Option {
+	return func(client *Client) {
+		client.maxRetries = maxRetries
+		client.retryBackoff = backoff
+		client.maxRetryBackoff = maxBackoff
+	}
+}
+
+// Cache schemas that have been returned, which speeds up lookups
+// of schemas that rarely change. Caching is enabled by default.
+func WithCaching(enabled bool) Option {
+	return func(client *Client) {
+		client.cachingEnabled = enabled
+	}
+}
+
+// Create goavro codecs for the Avro schemas that are returned,
+// which is enabled by default. Tooling that only inspects schemas
+// can disable it to skip parsing them.
+func WithCodecCreation(enabled bool) Option {
+	return func(client *Client) {
+		client.codecCreationEnabled = enabled
+	}
+}
+
+// NewTLSConfig loads a TLS configuration out of PEM files.
+// The client certificate and key are only loaded when both are set,
+// and the CA bundle replaces the system roots when it is set.
+func NewTLSConfig(certFile string, keyFile string, caFile string) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if certFile != "" && keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	if caFile != "" {
+		caBundle, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caBundle) {
+			return nil, errors.New("no certificate found in CA bundle")
+		}
+		config.RootCAs = pool
+	}
+	return config, nil
+}
@@ -0,0 +1,139 @@
+package schemaregistry
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+type SchemaType string
+
+const (
+	Protobuf SchemaType = "PROTOBUF"
+	Avro     SchemaType = "AVRO"
+	Json     SchemaType = "JSON"
+)
+
+func (s SchemaType) String() string {
+	return string(s)
+}
+
+// CompatibilityLevel is the rule Schema Registry applies
+// when a new schema version is registered under a subject.
+type CompatibilityLevel string
+
+const (
+	None               CompatibilityLevel = "NONE"
+	Backward           CompatibilityLevel = "BACKWARD"
+	BackwardTransitive CompatibilityLevel = "BACKWARD_TRANSITIVE"
+	Forward            CompatibilityLevel = "FORWARD"
+	ForwardTransitive  CompatibilityLevel = "FORWARD_TRANSITIVE"
+	Full               CompatibilityLevel = "FULL"
+	FullTransitive     CompatibilityLevel = "FULL_TRANSITIVE"
+)
+
+func (c CompatibilityLevel) String() string {
+	return string(c)
+}
+
+// Reference points to a schema registered under another
+// subject that a schema imports, such as a Protobuf import.
+type Reference struct {
+	Name    string `json:"name"`
+	Subject string `json:"subject"`
+	Version int    `json:"version"`
+}
+
+// Schema is a data structure that holds all
+// the relevant information about schemas.
+type Schema struct {
+	id         int
+	subject    string
+	version    int
+	schema     string
+	schemaType SchemaType
+	references []Reference
+	codec      *goavro.Codec
+}
+
+// NewSchema creates a schema out of its registration details,
+// creating its codec when it is an Avro schema. It is meant for
+// implementations of IClient that do not talk to Schema Registry.
+func NewSchema(id int, subject string, version int, schema string, schemaType SchemaType, references []Reference) (*Schema, error) {
+	return newSchema(id, subject, version, schema, schemaType, references, true)
+}
+
+func newSchema(id int, subject string, version int, schema string, schemaType SchemaType, references []Reference, codecCreationEnabled bool) (*Schema, error) {
+	if schemaType == "" {
+		schemaType = Avro
+	}
+	var codec *goavro.Codec
+	if codecCreationEnabled && schemaType == Avro {
+		var err error
+		codec, err = goavro.NewCodec(schema)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &Schema{
+		id:         id,
+		subject:    subject,
+		version:    version,
+		schema:     schema,
+		schemaType: schemaType,
+		references: references,
+		codec:      codec,
+	}, nil
+}
+
+// ID ensures access to ID
+func (schema *Schema) ID() int {
+	return schema.id
+}
+
+// Subject ensures access to Subject, which is empty
+// for schemas retrieved by their ID
+func (schema *Schema) Subject() string {
+	return schema.subject
+}
+
+// Version ensures access to Version, which is zero
+// for schemas retrieved by their ID
+func (schema *Schema) Version() int {
+	return schema.version
+}
+
+// Schema ensures access to Schema
+func (schema *Schema) Schema() string {
+	return schema.schema
+}
+
+// SchemaType ensures access to SchemaType
+func (schema *Schema) SchemaType() SchemaType {
+	return schema.schemaType
+}
+
+// References ensures access to References
+func (schema *Schema) References() []Reference {
+	return schema.references
+}
+
+// Codec ensures access to Codec, which is nil
+// for schemas that are not Avro schemas
+func (schema *Schema) Codec() *goavro.Codec {
+	return schema.codec
+}
+
+func (schema Schema) FullName() string {
+	var dat map[string]interface{}
+	_ = json.Unmarshal([]byte(schema.schema), &dat)
+	name, _ := dat["name"].(string)
+	namespace, _ := dat["namespace"].(string)
+	switch namespace {
+	case "":
+		return name
+	default:
+		return fmt.Sprintf("%s.%s", namespace, name)
+	}
+}
@@ -2,25 +2,148 @@ package messagebus
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"encoding/binary"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
 
+	"github.com/actgardner/gogen-avro/v7/container"
 	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/kata-ai/messagebus-golang-kafka/messagebus/internal/avro"
+	"github.com/kata-ai/messagebus-golang-kafka/messagebus/schemaregistry"
+	"github.com/linkedin/goavro/v2"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
 )
 
 type Serializer struct {
 	schemaRegistry ISchemaRegistryClient
 	strategy       SubjectStrategy
+	valueTypes     *valueTypeRegistry
+	readerSchemas  *readerSchemaRegistry
+	schemaIDs      *schemaIDCache
+	jsonSchemas    *jsonSchemaRegistry
+	protoSchemas   *protobufSchemaRegistry
+	// autoRegisterSchemas registers record schemas that are
+	// missing from their subject, which is the default
+	autoRegisterSchemas bool
+	// useLatestVersion writes records with the latest version
+	// of their subject when schemas are not auto-registered
+	useLatestVersion bool
+	// headerEnvelope writes the message key in headers and the
+	// record key of the producer record as Kafka record key
+	headerEnvelope bool
 }
 
+type SerializerOption func(s *Serializer)
+
+// ErrSchemaNotRegistered is returned when auto-registration is
+// disabled and the schema of a record is not registered.
+var ErrSchemaNotRegistered = errors.New("schema is not registered")
+
 type SerializedProducerRecord struct {
-	Key   []byte
-	Value []byte
+	Key     []byte
+	Value   []byte
+	Headers []kafka.Header
+}
+
+func NewSerializer(srUrl string, strategy SubjectStrategy, opts ...SerializerOption) (*Serializer, error) {
+	return NewSerializerWithClient(schemaregistry.NewClient(srUrl), strategy, opts...)
+}
+
+// NewSerializerWithClient creates a serializer that looks schemas
+// up with the given client rather than with a Schema Registry URL.
+func NewSerializerWithClient(client ISchemaRegistryClient, strategy SubjectStrategy, opts ...SerializerOption) (*Serializer, error) {
+	serializer := &Serializer{
+		schemaRegistry:      client,
+		strategy:            strategy,
+		valueTypes:          newValueTypeRegistry(),
+		readerSchemas:       newReaderSchemaRegistry(),
+		schemaIDs:           newSchemaIDCache(),
+		jsonSchemas:         newJSONSchemaRegistry(),
+		protoSchemas:        newProtobufSchemaRegistry(),
+		autoRegisterSchemas: true,
+	}
+	for _, opt := range opts {
+		opt(serializer)
+	}
+	return serializer, nil
 }
 
-func NewSerializer(srUrl string, strategy SubjectStrategy) (*Serializer, error) {
-	client := createSchemaRegistryClient(srUrl)
-	return &Serializer{schemaRegistry: client, strategy: strategy}, nil
+// Configure whether record schemas missing from their subject are
+// registered when sending. When disabled, the schema must already be
+// registered under the subject, otherwise sending fails with
+// ErrSchemaNotRegistered.
+func WithAutoRegisterSchemas(enabled bool) SerializerOption {
+	return func(s *Serializer) {
+		s.autoRegisterSchemas = enabled
+	}
+}
+
+// Configure records to be written with the latest version registered
+// under their subject rather than with their own schema, which must be
+// resolvable into the latest version. Only applies when auto-registration
+// is disabled.
+func WithUseLatestVersion(enabled bool) SerializerOption {
+	return func(s *Serializer) {
+		s.useLatestVersion = enabled
+	}
+}
+
+// Configure the message key to travel in Kafka headers rather than
+// as an Avro record key, so that the Kafka record key is the
+// RecordKey of the producer record, such as a business key keeping
+// related messages in one partition. Messages are deserialized from
+// either format regardless of this option, so consumers must be
+// upgraded before producers enable it.
+// Example:
+// 		WithSerializerOptions(WithHeaderEnvelope(true))
+func WithHeaderEnvelope(enabled bool) SerializerOption {
+	return func(s *Serializer) {
+		s.headerEnvelope = enabled
+	}
+}
+
+// RegisterValueType makes values consumed from a topic, or written
+// under a value subject, decode into the gogen-avro record returned
+// by newRecord instead of a generic map. The writer schema of each
+// message is resolved into the record schema, so producers may use
+// any compatible version of it. Topic registrations take precedence
+// over subject registrations.
+func (s Serializer) RegisterValueType(topicOrSubject string, newRecord func() container.AvroRecord) {
+	s.valueTypes.register(topicOrSubject, func() interface{} {
+		return newRecord()
+	})
+}
+
+// RegisterJSONValueType makes JSON Schema values consumed from a topic,
+// or written under a value subject, decode into the Go value returned
+// by newValue, which must be a pointer, instead of a generic map.
+func (s Serializer) RegisterJSONValueType(topicOrSubject string, newValue func() interface{}) {
+	s.valueTypes.register(topicOrSubject, newValue)
+}
+
+// RegisterProtobufValueType makes Protobuf values consumed from a topic,
+// or written under a value subject, decode into the Go message returned
+// by newMessage instead of a dynamic message.
+func (s Serializer) RegisterProtobufValueType(topicOrSubject string, newMessage func() proto.Message) {
+	s.valueTypes.register(topicOrSubject, func() interface{} {
+		return newMessage()
+	})
+}
+
+// RegisterReaderSchema makes values consumed from a topic decode
+// with the given Avro schema rather than the schema they were written
+// with. Fields added by the writer are dropped, fields missing from
+// the writer take their defaults and numeric types are promoted.
+// Messages whose writer schema cannot be resolved into the reader
+// schema fail to deserialize.
+func (s Serializer) RegisterReaderSchema(topic string, schema string) error {
+	return s.readerSchemas.register(topic, schema)
 }
 
 func (s Serializer) Serialize(topic string, record *ProducerRecord) (*SerializedProducerRecord, error) {
@@ -29,6 +152,13 @@ func (s Serializer) Serialize(topic string, record *ProducerRecord) (*Serialized
 		return nil, err
 	}
 	record.Key.SetValueSubject(valueSubject)
+	if s.headerEnvelope {
+		return &SerializedProducerRecord{
+			Key:     record.RecordKey,
+			Value:   valueBytes,
+			Headers: messageKeyHeaders(record.Key),
+		}, nil
+	}
 	keyBytes, err := s.serializeKey(topic, record)
 	if err != nil {
 		return nil, err
@@ -41,57 +171,297 @@ func (s Serializer) Serialize(topic string, record *ProducerRecord) (*Serialized
 }
 
 func (s Serializer) serializeValue(topic string, record *ProducerRecord) ([]byte, string, error) {
-	schemaStr := record.Value.Schema()
-	valueSubject, err := prepareSubjectName(topic, schemaStr, s.strategy, false)
+	if message, ok := record.Value.(proto.Message); ok {
+		return s.serializeProtobufValue(topic, message)
+	}
+	avroRecord, ok := record.Value.(container.AvroRecord)
+	if !ok {
+		return s.serializeJSONValue(topic, record.Value)
+	}
+	schemaStr := avroRecord.Schema()
+	valueSubject, err := prepareSubjectName(topic, recordFullName(schemaStr), s.strategy, false)
 	if err != nil {
 		return nil, "", err
 	}
+	data, err := s.serializeRecord(valueSubject, avroRecord)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, valueSubject, nil
+}
 
-	schema, err := s.schemaRegistry.createSchema(valueSubject, schemaStr, Avro, false)
+// serializeJSONValue writes values that are not Avro records as JSON,
+// with the JSON Schema generated from their type and validated against
+// the JSON Schema of their subject.
+func (s Serializer) serializeJSONValue(topic string, value interface{}) ([]byte, string, error) {
+	schemaStr, err := s.jsonSchemas.schemaOf(value)
 	if err != nil {
 		return nil, "", err
 	}
-	var buf bytes.Buffer
-	err = record.Value.Serialize(&buf)
+	valueSubject, err := prepareSubjectName(topic, recordFullName(schemaStr), s.strategy, false)
 	if err != nil {
 		return nil, "", err
 	}
-	schemaIDBytes := make([]byte, 4)
-	binary.BigEndian.PutUint32(schemaIDBytes, uint32(schema.ID()))
-
-	var data []byte
-	data = append(data, byte(0))
-	data = append(data, schemaIDBytes...)
-	data = append(data, buf.Bytes()...)
+	schema, err := s.resolveJSONSchema(valueSubject, schemaStr)
+	if err != nil {
+		return nil, "", err
+	}
+	payload, err := json.Marshal(value)
+	if err != nil {
+		return nil, "", err
+	}
+	err = schema.validate(payload)
+	if err != nil {
+		return nil, "", err
+	}
+	return frameWireFormat(schema.id, payload), valueSubject, nil
+}
 
-	return data, valueSubject, nil
+// serializeProtobufValue writes Protobuf messages prefixed with the
+// indexes of their message in the .proto schema of their subject.
+func (s Serializer) serializeProtobufValue(topic string, message proto.Message) ([]byte, string, error) {
+	descriptor := message.ProtoReflect().Descriptor()
+	valueSubject, err := prepareSubjectName(topic, string(descriptor.FullName()), s.strategy, false)
+	if err != nil {
+		return nil, "", err
+	}
+	schema, err := s.resolveProtobufSchema(valueSubject, descriptor.ParentFile())
+	if err != nil {
+		return nil, "", err
+	}
+	payload, err := proto.Marshal(message)
+	if err != nil {
+		return nil, "", err
+	}
+	payload = append(encodeMessageIndexes(protobufMessageIndexes(descriptor)), payload...)
+	return frameWireFormat(schema.id, payload), valueSubject, nil
 }
 
 func (s Serializer) serializeKey(topic string, record *ProducerRecord) ([]byte, error) {
-	subject, err := prepareSubjectName(topic, record.Key.Schema(), s.strategy, true)
+	subject, err := prepareSubjectName(topic, recordFullName(record.Key.Schema()), s.strategy, true)
 	if err != nil {
 		return nil, err
 	}
-	schema, err := s.schemaRegistry.createSchema(subject, record.Key.Schema(), Avro, true)
+	return s.serializeRecord(subject, record.Key)
+}
+
+func (s Serializer) serializeRecord(subject string, record container.AvroRecord) ([]byte, error) {
+	schema, err := s.resolveSchema(subject, record)
 	if err != nil {
 		return nil, err
 	}
-	schemaIDBytes := make([]byte, 4)
-	binary.BigEndian.PutUint32(schemaIDBytes, uint32(schema.ID()))
-
 	var buf bytes.Buffer
-	err = record.Key.Serialize(&buf)
+	err = record.Serialize(&buf)
+	if err != nil {
+		return nil, err
+	}
+	payload := buf.Bytes()
+	if schema.transcode != nil {
+		payload, err = schema.transcode(payload)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return frameWireFormat(schema.id, payload), nil
+}
+
+// frameWireFormat prefixes a payload with the magic byte
+// and schema ID of the Confluent wire format.
+func frameWireFormat(schemaID int, payload []byte) []byte {
+	schemaIDBytes := make([]byte, 4)
+	binary.BigEndian.PutUint32(schemaIDBytes, uint32(schemaID))
 
 	var data []byte
 	data = append(data, byte(0))
 	data = append(data, schemaIDBytes...)
-	data = append(data, buf.Bytes()...)
-	return data, nil
+	data = append(data, payload...)
+	return data
+}
+
+// resolveSchema returns the schema records are written with under
+// the subject, registering or looking it up the first time this
+// process sees it.
+func (s Serializer) resolveSchema(subject string, record container.AvroRecord) (*registeredSchema, error) {
+	return s.schemaIDs.get(subject, schemaFingerprint(record), func() (*registeredSchema, error) {
+		if s.autoRegisterSchemas {
+			schema, err := s.schemaRegistry.CreateSchema(subject, record.Schema(), schemaregistry.Avro)
+			if err != nil {
+				return nil, err
+			}
+			return &registeredSchema{id: schema.ID()}, nil
+		}
+		if s.useLatestVersion {
+			schema, err := s.schemaRegistry.GetLatestSchema(subject)
+			if err != nil {
+				return nil, notRegisteredError(subject, err)
+			}
+			return newLatestSchema(schema, record)
+		}
+		schema, err := s.schemaRegistry.LookupSchema(subject, record.Schema(), schemaregistry.Avro)
+		if err != nil {
+			return nil, notRegisteredError(subject, err)
+		}
+		return &registeredSchema{id: schema.ID()}, nil
+	})
+}
+
+// resolveJSONSchema is resolveSchema for JSON Schemas. Values are
+// validated against the schema of the ID they are written with.
+func (s Serializer) resolveJSONSchema(subject string, schemaStr string) (*registeredSchema, error) {
+	fingerprint := sha256.Sum256([]byte(schemaStr))
+	return s.schemaIDs.get(subject, fingerprint[:], func() (*registeredSchema, error) {
+		var schema *schemaregistry.Schema
+		var err error
+		switch {
+		case s.autoRegisterSchemas:
+			schema, err = s.schemaRegistry.CreateSchema(subject, schemaStr, schemaregistry.Json)
+		case s.useLatestVersion:
+			schema, err = s.schemaRegistry.GetLatestSchema(subject)
+			err = notRegisteredError(subject, err)
+		default:
+			schema, err = s.schemaRegistry.LookupSchema(subject, schemaStr, schemaregistry.Json)
+			err = notRegisteredError(subject, err)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if schema.SchemaType() != schemaregistry.Json {
+			return nil, fmt.Errorf("schema %d of subject %s is not a JSON Schema", schema.ID(), subject)
+		}
+		validate := func(payload []byte) error {
+			return s.jsonSchemas.validate(schema, payload)
+		}
+		return &registeredSchema{id: schema.ID(), validate: validate}, nil
+	})
+}
+
+// resolveProtobufSchema is resolveSchema for .proto schemas, which
+// are registered, or looked up, along with the files they import.
+func (s Serializer) resolveProtobufSchema(subject string, file protoreflect.FileDescriptor) (*registeredSchema, error) {
+	schemaStr, err := s.protoSchemas.schemaOf(file)
+	if err != nil {
+		return nil, err
+	}
+	fingerprint := sha256.Sum256([]byte(schemaStr))
+	return s.schemaIDs.get(subject, fingerprint[:], func() (*registeredSchema, error) {
+		references, err := s.protobufReferences(file)
+		if err != nil {
+			return nil, err
+		}
+		var schema *schemaregistry.Schema
+		switch {
+		case s.autoRegisterSchemas:
+			schema, err = s.schemaRegistry.CreateSchema(subject, schemaStr, schemaregistry.Protobuf, references...)
+		case s.useLatestVersion:
+			schema, err = s.schemaRegistry.GetLatestSchema(subject)
+			err = notRegisteredError(subject, err)
+		default:
+			schema, err = s.schemaRegistry.LookupSchema(subject, schemaStr, schemaregistry.Protobuf, references...)
+			err = notRegisteredError(subject, err)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if schema.SchemaType() != schemaregistry.Protobuf {
+			return nil, fmt.Errorf("schema %d of subject %s is not a Protobuf schema", schema.ID(), subject)
+		}
+		return &registeredSchema{id: schema.ID()}, nil
+	})
+}
+
+// protobufReferences registers, or looks up, the files a .proto
+// schema imports under subjects named after them, returning the
+// references to them.
+func (s Serializer) protobufReferences(file protoreflect.FileDescriptor) ([]schemaregistry.Reference, error) {
+	var references []schemaregistry.Reference
+	imports := file.Imports()
+	for i := 0; i < imports.Len(); i++ {
+		imported := imports.Get(i).FileDescriptor
+		if isWellKnownProtobufFile(imported.Path()) {
+			continue
+		}
+		nested, err := s.protobufReferences(imported)
+		if err != nil {
+			return nil, err
+		}
+		schemaStr, err := s.protoSchemas.schemaOf(imported)
+		if err != nil {
+			return nil, err
+		}
+		var schema *schemaregistry.Schema
+		if s.autoRegisterSchemas {
+			schema, err = s.schemaRegistry.CreateSchema(imported.Path(), schemaStr, schemaregistry.Protobuf, nested...)
+		} else {
+			schema, err = s.schemaRegistry.LookupSchema(imported.Path(), schemaStr, schemaregistry.Protobuf, nested...)
+			err = notRegisteredError(imported.Path(), err)
+		}
+		if err != nil {
+			return nil, err
+		}
+		references = append(references, schemaregistry.Reference{
+			Name:    imported.Path(),
+			Subject: imported.Path(),
+			Version: schema.Version(),
+		})
+	}
+	return references, nil
 }
 
+// newLatestSchema writes records with the latest version of their
+// subject, transcoding them when their schema is a different one.
+func newLatestSchema(latest *schemaregistry.Schema, record container.AvroRecord) (*registeredSchema, error) {
+	recordCodec, err := goavro.NewCodec(record.Schema())
+	if err != nil {
+		return nil, err
+	}
+	latestCodec := latest.Codec()
+	if latestCodec == nil {
+		latestCodec, err = goavro.NewCodec(latest.Schema())
+		if err != nil {
+			return nil, err
+		}
+	}
+	if recordCodec.CanonicalSchema() == latestCodec.CanonicalSchema() {
+		return &registeredSchema{id: latest.ID()}, nil
+	}
+	project, err := avro.Resolve(record.Schema(), latest.Schema())
+	if err != nil {
+		return nil, fmt.Errorf("cannot write %T with latest schema %d: %v", record, latest.ID(), err)
+	}
+	transcode := func(payload []byte) ([]byte, error) {
+		native, _, err := recordCodec.NativeFromBinary(payload)
+		if err != nil {
+			return nil, err
+		}
+		projected, err := project(native)
+		if err != nil {
+			return nil, err
+		}
+		return latestCodec.BinaryFromNative(nil, projected)
+	}
+	return &registeredSchema{id: latest.ID(), transcode: transcode}, nil
+}
+
+func notRegisteredError(subject string, err error) error {
+	var registryErr *schemaregistry.Error
+	if errors.As(err, &registryErr) && registryErr.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w under subject %s: %v", ErrSchemaNotRegistered, subject, err)
+	}
+	return err
+}
+
+// Deserialize reads the message key from headers when the message
+// carries one there, taking the Kafka record key as RecordKey, and
+// otherwise decodes the Kafka record key as an Avro message key.
 func (s Serializer) Deserialize(message *kafka.Message) (*ConsumerRecord, error) {
-	var key *MessageKey
-	if message.Key != nil {
+	key, err := messageKeyFromHeaders(message.Headers)
+	if err != nil {
+		return nil, err
+	}
+	var recordKey []byte
+	if key != nil {
+		recordKey = message.Key
+	} else if message.Key != nil {
 		avroDeserializedKey, err := s.deserializeBytes(message.Key)
 		if err != nil {
 			return nil, err
@@ -101,13 +471,16 @@ func (s Serializer) Deserialize(message *kafka.Message) (*ConsumerRecord, error)
 			return nil, err
 		}
 	}
+	topic := *message.TopicPartition.Topic
 	var value map[string]interface{}
+	var typedValue interface{}
 	if message.Value != nil {
-		avroDeserializedValue, err := s.deserializeBytes(message.Value)
-		if err != nil {
-			return nil, err
+		var valueSubject string
+		if key != nil {
+			valueSubject = key.ValueSubject
 		}
-		value, err = s.decodeValue(avroDeserializedValue)
+		var err error
+		value, typedValue, err = s.deserializeMessageValue(topic, valueSubject, message.Value)
 		if err != nil {
 			return nil, err
 		}
@@ -115,8 +488,10 @@ func (s Serializer) Deserialize(message *kafka.Message) (*ConsumerRecord, error)
 
 	return &ConsumerRecord{
 		Key:       key,
-		Topic:     *message.TopicPartition.Topic,
+		RecordKey: recordKey,
+		Topic:     topic,
 		Value:     value,
+		Record:    typedValue,
 		Partition: message.TopicPartition.Partition,
 		Offset:    message.TopicPartition.Offset.String(),
 		Timestamp: message.Timestamp,
@@ -124,20 +499,184 @@ func (s Serializer) Deserialize(message *kafka.Message) (*ConsumerRecord, error)
 }
 
 func (s Serializer) deserializeBytes(bytes []byte) ([]byte, error) {
-	schemaID := binary.BigEndian.Uint32(bytes[1:5])
-	schema, err := s.schemaRegistry.getSchema(int(schemaID))
+	schemaID, payload, err := splitWireFormat(bytes)
 	if err != nil {
 		return nil, err
 	}
-	native, _, err := schema.Codec().NativeFromBinary(bytes[5:])
+	schema, err := s.schemaRegistry.GetSchema(schemaID)
 	if err != nil {
 		return nil, err
 	}
-	deserializedBytes, err := schema.Codec().TextualFromNative(nil, native)
+	codec, err := avroCodecOf(schema)
+	if err != nil {
+		return nil, err
+	}
+	native, _, err := codec.NativeFromBinary(payload)
+	if err != nil {
+		return nil, err
+	}
+	deserializedBytes, err := codec.TextualFromNative(nil, native)
 	return deserializedBytes, err
 
 }
 
+// avroCodecOf returns the codec of an Avro schema, creating it when
+// the client was told not to, and fails for other schema types.
+func avroCodecOf(schema *schemaregistry.Schema) (*goavro.Codec, error) {
+	if schema.SchemaType() != schemaregistry.Avro {
+		return nil, fmt.Errorf("schema %d is a %s schema, not an Avro schema", schema.ID(), schema.SchemaType())
+	}
+	if codec := schema.Codec(); codec != nil {
+		return codec, nil
+	}
+	return goavro.NewCodec(schema.Schema())
+}
+
+// deserializeValue converts a value into its textual form,
+// using the reader schema of the topic when there is one.
+func (s Serializer) deserializeValue(topic string, bytes []byte) ([]byte, error) {
+	reader := s.readerSchemas.lookup(topic)
+	if reader == nil {
+		return s.deserializeBytes(bytes)
+	}
+	schemaID, payload, err := splitWireFormat(bytes)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := s.schemaRegistry.GetSchema(schemaID)
+	if err != nil {
+		return nil, err
+	}
+	codec, err := avroCodecOf(schema)
+	if err != nil {
+		return nil, err
+	}
+	native, _, err := codec.NativeFromBinary(payload)
+	if err != nil {
+		return nil, err
+	}
+	projected, err := s.readerSchemas.project(topic, reader, schema, native)
+	if err != nil {
+		return nil, err
+	}
+	return reader.codec.TextualFromNative(nil, projected)
+}
+
+// deserializeMessageValue decodes a value into a generic map, or into
+// the type registered for its topic or value subject, following the
+// type of the schema it was written with.
+func (s Serializer) deserializeMessageValue(topic string, valueSubject string, bytes []byte) (map[string]interface{}, interface{}, error) {
+	schemaID, payload, err := splitWireFormat(bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	schema, err := s.schemaRegistry.GetSchema(schemaID)
+	if err != nil {
+		return nil, nil, err
+	}
+	newValue := s.valueTypes.lookup(topic, valueSubject)
+	switch schema.SchemaType() {
+	case schemaregistry.Json:
+		return s.decodeJSONValue(schema, payload, newValue)
+	case schemaregistry.Protobuf:
+		return s.decodeProtobufValue(schema, payload, newValue)
+	}
+	if newValue != nil {
+		record, ok := newValue().(container.AvroRecord)
+		if !ok {
+			return nil, nil, fmt.Errorf("value type of topic %s is not an Avro record", topic)
+		}
+		err = s.valueTypes.decode(schema, payload, record)
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, record, nil
+	}
+	avroDeserializedValue, err := s.deserializeValue(topic, bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	value, err := s.decodeValue(avroDeserializedValue)
+	if err != nil {
+		return nil, nil, err
+	}
+	return value, nil, nil
+}
+
+// decodeProtobufValue decodes a Protobuf payload into the registered
+// Go message, or into a dynamic message built from the schema it was
+// written with, whose JSON form is returned as generic map as well.
+func (s Serializer) decodeProtobufValue(schema *schemaregistry.Schema, payload []byte, newValue func() interface{}) (map[string]interface{}, interface{}, error) {
+	indexes, messageBytes, err := decodeMessageIndexes(payload)
+	if err != nil {
+		return nil, nil, err
+	}
+	if newValue != nil {
+		message, ok := newValue().(proto.Message)
+		if !ok {
+			return nil, nil, fmt.Errorf("value type for Protobuf schema %d is not a Protobuf message", schema.ID())
+		}
+		err = proto.Unmarshal(messageBytes, message)
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, message, nil
+	}
+	file, err := s.protoSchemas.descriptorOf(s.schemaRegistry, schema)
+	if err != nil {
+		return nil, nil, err
+	}
+	descriptor, err := messageByIndexes(file, indexes)
+	if err != nil {
+		return nil, nil, err
+	}
+	message := dynamicpb.NewMessage(descriptor)
+	err = proto.Unmarshal(messageBytes, message)
+	if err != nil {
+		return nil, nil, err
+	}
+	jsonBytes, err := protojson.Marshal(message)
+	if err != nil {
+		return nil, nil, err
+	}
+	value, err := s.decodeValue(jsonBytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	return value, message, nil
+}
+
+// decodeJSONValue validates a JSON payload against the schema it was
+// written with before decoding it.
+func (s Serializer) decodeJSONValue(schema *schemaregistry.Schema, payload []byte, newValue func() interface{}) (map[string]interface{}, interface{}, error) {
+	err := s.jsonSchemas.validate(schema, payload)
+	if err != nil {
+		return nil, nil, err
+	}
+	if newValue != nil {
+		typedValue := newValue()
+		err = json.Unmarshal(payload, typedValue)
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, typedValue, nil
+	}
+	value, err := s.decodeValue(payload)
+	if err != nil {
+		return nil, nil, err
+	}
+	return value, nil, nil
+}
+
+// splitWireFormat separates the schema ID from the Avro
+// payload of a message framed with the Confluent wire format.
+func splitWireFormat(bytes []byte) (int, []byte, error) {
+	if len(bytes) < 5 || bytes[0] != 0 {
+		return 0, nil, errors.New("message is not in Confluent wire format")
+	}
+	return int(binary.BigEndian.Uint32(bytes[1:5])), bytes[5:], nil
+}
+
 func (s Serializer) decodeKey(deserializedKey []byte) (*MessageKey, error) {
 	var key MessageKey
 	err := json.Unmarshal(deserializedKey, &key)
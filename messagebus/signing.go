@@ -0,0 +1,233 @@
+package messagebus
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// Headers carrying the signature of a message.
+const (
+	signatureKeyIdHeader = keyHeaderPrefix + "signature-key-id"
+	signatureHeader      = keyHeaderPrefix + "signature"
+)
+
+// ErrUnsigned is returned when consuming a message without
+// signature while signatures are verified.
+var ErrUnsigned = errors.New("message is not signed")
+
+// HMACSigner signs messages with HMAC-SHA256, which requires
+// consumers to share the secret.
+type HMACSigner struct {
+	keyId  string
+	secret []byte
+}
+
+func NewHMACSigner(keyId string, secret []byte) *HMACSigner {
+	return &HMACSigner{keyId: keyId, secret: secret}
+}
+
+func (s *HMACSigner) KeyId() string {
+	return s.keyId
+}
+
+func (s *HMACSigner) Sign(data []byte) ([]byte, error) {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write(data)
+	return mac.Sum(nil), nil
+}
+
+// Ed25519Signer signs messages with an Ed25519 private key, so that
+// consumers only need the public key.
+type Ed25519Signer struct {
+	keyId      string
+	privateKey ed25519.PrivateKey
+}
+
+func NewEd25519Signer(keyId string, privateKey ed25519.PrivateKey) *Ed25519Signer {
+	return &Ed25519Signer{keyId: keyId, privateKey: privateKey}
+}
+
+func (s *Ed25519Signer) KeyId() string {
+	return s.keyId
+}
+
+func (s *Ed25519Signer) Sign(data []byte) ([]byte, error) {
+	return ed25519.Sign(s.privateKey, data), nil
+}
+
+// TrustedKeySet is an ISignatureVerifier holding the keys messages
+// may be signed with. Keys are bound to the origin service they sign
+// for, so that a service cannot sign messages as another one.
+type TrustedKeySet struct {
+	keys map[string]trustedKey
+	lock sync.RWMutex
+}
+
+type trustedKey struct {
+	originService string
+	secret        []byte
+	publicKey     ed25519.PublicKey
+}
+
+func NewTrustedKeySet() *TrustedKeySet {
+	return &TrustedKeySet{keys: make(map[string]trustedKey)}
+}
+
+// errKeyWithoutOrigin is returned when trusting a key that is not
+// bound to an origin service.
+var errKeyWithoutOrigin = errors.New("trusted keys must be bound to an origin service")
+
+// AddHMACKey trusts an HMAC-SHA256 secret for the origin service.
+func (s *TrustedKeySet) AddHMACKey(keyId string, originService string, secret []byte) error {
+	if originService == "" {
+		return errKeyWithoutOrigin
+	}
+	s.lock.Lock()
+	s.keys[keyId] = trustedKey{originService: originService, secret: secret}
+	s.lock.Unlock()
+	return nil
+}
+
+// AddEd25519Key trusts an Ed25519 public key for the origin service.
+func (s *TrustedKeySet) AddEd25519Key(keyId string, originService string, publicKey ed25519.PublicKey) error {
+	if originService == "" {
+		return errKeyWithoutOrigin
+	}
+	s.lock.Lock()
+	s.keys[keyId] = trustedKey{originService: originService, publicKey: publicKey}
+	s.lock.Unlock()
+	return nil
+}
+
+// RemoveKey stops trusting a key, such as a revoked one.
+func (s *TrustedKeySet) RemoveKey(keyId string) {
+	s.lock.Lock()
+	delete(s.keys, keyId)
+	s.lock.Unlock()
+}
+
+// Verify checks a signature made with a trusted key, returning the
+// origin service the key is bound to.
+func (s *TrustedKeySet) Verify(keyId string, data []byte, signature []byte) (string, error) {
+	s.lock.RLock()
+	key, ok := s.keys[keyId]
+	s.lock.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("message is signed with untrusted key %s", keyId)
+	}
+	var valid bool
+	if key.publicKey != nil {
+		valid = ed25519.Verify(key.publicKey, data, signature)
+	} else {
+		mac := hmac.New(sha256.New, key.secret)
+		mac.Write(data)
+		valid = hmac.Equal(mac.Sum(nil), signature)
+	}
+	if !valid {
+		return "", fmt.Errorf("invalid signature with key %s", keyId)
+	}
+	return key.originService, nil
+}
+
+// signedBytes returns what the signature of a message covers: its
+// topic, its record key, its value and its headers other than the
+// signature, each prefixed with its length. Covering the topic keeps
+// a signed message from being replayed to another topic.
+func signedBytes(topic string, key []byte, value []byte, headers []kafka.Header) []byte {
+	var data []byte
+	appendPart := func(part []byte) {
+		data = binary.BigEndian.AppendUint32(data, uint32(len(part)))
+		data = append(data, part...)
+	}
+	appendPart([]byte(topic))
+	appendPart(key)
+	appendPart(value)
+	for _, header := range headers {
+		if header.Key == signatureKeyIdHeader || header.Key == signatureHeader {
+			continue
+		}
+		appendPart([]byte(header.Key))
+		appendPart(header.Value)
+	}
+	return data
+}
+
+// sign adds the signature headers to a record serialized for a topic.
+func (p *ProducerConfiguration) sign(topic string, record *SerializedProducerRecord) error {
+	if p == nil || p.Signer == nil {
+		return nil
+	}
+	signature, err := p.Signer.Sign(signedBytes(topic, record.Key, record.Value, record.Headers))
+	if err != nil {
+		return fmt.Errorf("cannot sign message: %v", err)
+	}
+	record.Headers = append(record.Headers,
+		kafka.Header{Key: signatureKeyIdHeader, Value: []byte(p.Signer.KeyId())},
+		kafka.Header{Key: signatureHeader, Value: signature},
+	)
+	return nil
+}
+
+// verifySignature checks the signature of a consumed message,
+// returning the origin service its key is bound to.
+func (c *ConsumerConfiguration) verifySignature(message *kafka.Message) (string, error) {
+	var keyId string
+	var signature []byte
+	for _, header := range message.Headers {
+		switch header.Key {
+		case signatureKeyIdHeader:
+			keyId = string(header.Value)
+		case signatureHeader:
+			signature = header.Value
+		}
+	}
+	if keyId == "" || signature == nil {
+		return "", ErrUnsigned
+	}
+	data := signedBytes(*message.TopicPartition.Topic, message.Key, message.Value, message.Headers)
+	originService, err := c.SignatureVerifier.Verify(keyId, data, signature)
+	if err == nil && originService == "" {
+		return "", fmt.Errorf("key %s is not bound to an origin service", keyId)
+	}
+	return originService, err
+}
+
+// validateSignatureVerification checks that forged messages can be
+// dead-lettered.
+func (c *ConsumerConfiguration) validateSignatureVerification(producer *kafka.Producer) error {
+	if c.SignatureVerifier == nil || c.SignatureAction != SIGNATURE_DEAD_LETTER {
+		return nil
+	}
+	if c.DeadLetterTopic == "" {
+		return errors.New("dead-letter topic required to dead-letter unsigned messages")
+	}
+	if producer == nil {
+		return errors.New("producer configuration required to dead-letter unsigned messages")
+	}
+	return nil
+}
+
+// reject reports and commits a message that failed verification,
+// consumed as the given chunks, sending them to the dead-letter topic
+// first with the SIGNATURE_DEAD_LETTER action, which retries until the
+// bus disconnects and leaves the message uncommitted then.
+func (m *MessageBus) reject(message *kafka.Message, chunks []*kafka.Message, reason error) {
+	_, _ = fmt.Fprintf(os.Stderr, "rejected message at offset %v of %s: %v\n",
+		message.TopicPartition.Offset, *message.TopicPartition.Topic, reason)
+	if m.consumerConfig.SignatureAction == SIGNATURE_DEAD_LETTER {
+		err := m.deadLetter(chunks, reason.Error())
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+			return
+		}
+	}
+	m.commit(message)
+}
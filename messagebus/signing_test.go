@@ -0,0 +1,48 @@
+package messagebus
+
+import (
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+func TestSignatureCoversTopic(t *testing.T) {
+	keys := NewTrustedKeySet()
+	if err := keys.AddHMACKey("billing", "", []byte("secret")); err == nil {
+		t.Error("trusted a key without origin service")
+	}
+	if err := keys.AddHMACKey("billing", "billing-service", []byte("secret")); err != nil {
+		t.Fatalf("cannot trust key: %v", err)
+	}
+	producer := &ProducerConfiguration{Signer: NewHMACSigner("billing", []byte("secret"))}
+	record := &SerializedProducerRecord{Key: []byte("key"), Value: []byte("value")}
+	if err := producer.sign("invoices", record); err != nil {
+		t.Fatalf("cannot sign record: %v", err)
+	}
+	consumer := &ConsumerConfiguration{SignatureVerifier: keys}
+
+	tests := []struct {
+		topic string
+		valid bool
+	}{
+		{"invoices", true},
+		{"refunds", false},
+	}
+	for _, test := range tests {
+		t.Run(test.topic, func(t *testing.T) {
+			topic := test.topic
+			origin, err := consumer.verifySignature(&kafka.Message{
+				TopicPartition: kafka.TopicPartition{Topic: &topic},
+				Key:            record.Key,
+				Value:          record.Value,
+				Headers:        record.Headers,
+			})
+			if test.valid && (err != nil || origin != "billing-service") {
+				t.Errorf("verified origin %q, %v", origin, err)
+			}
+			if !test.valid && err == nil {
+				t.Error("signature verified on another topic")
+			}
+		})
+	}
+}
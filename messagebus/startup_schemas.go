@@ -0,0 +1,181 @@
+package messagebus
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path"
+
+	"github.com/actgardner/gogen-avro/v7/container"
+	"github.com/kata-ai/messagebus-golang-kafka/messagebus/internal/avro"
+	"github.com/kata-ai/messagebus-golang-kafka/messagebus/schemaregistry"
+)
+
+// startupSchemas are the schemas of a topic the bus prepares
+// before NewMessageBus returns, given either as records or as
+// a directory of .avsc files.
+type startupSchemas struct {
+	topic   string
+	records []container.AvroRecord
+	fsys    fs.FS
+	dir     string
+}
+
+// PrepareSchemas checks and registers, or looks up, the schemas of
+// records sent to a topic ahead of the first send, along with the
+// schema of message keys unless they are carried in headers. Subjects
+// follow the subject strategy and schemas are handled the same way
+// sending would handle them, after checking their compatibility when
+// they are auto-registered. The returned error joins the errors of
+// every schema.
+func (s Serializer) PrepareSchemas(topic string, records ...container.AvroRecord) error {
+	var errs []error
+	// Message keys carried in headers have no schema
+	if !s.headerEnvelope {
+		keySubject, err := prepareSubjectName(topic, recordFullName((&MessageKey{}).Schema()), s.strategy, true)
+		if err != nil {
+			return err
+		}
+		errs = append(errs, s.prepareRecord(keySubject, &MessageKey{}))
+	}
+	for _, record := range records {
+		subject, err := prepareSubjectName(topic, recordFullName(record.Schema()), s.strategy, false)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		errs = append(errs, s.prepareRecord(subject, record))
+	}
+	return errors.Join(errs...)
+}
+
+// PrepareSchemaFiles is PrepareSchemas for the value schemas of the
+// .avsc files of a directory, for schemas without generated records.
+func (s Serializer) PrepareSchemaFiles(topic string, fsys fs.FS, dir string) error {
+	files, err := fs.Glob(fsys, path.Join(dir, "*.avsc"))
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no .avsc file found in %s", dir)
+	}
+	var errs []error
+	for _, file := range files {
+		schemaBytes, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		schema := string(schemaBytes)
+		subject, err := prepareSubjectName(topic, recordFullName(schema), s.strategy, false)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		err = s.prepareSchema(subject, schema)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", file, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s Serializer) prepareRecord(subject string, record container.AvroRecord) error {
+	err := s.checkCompatibility(subject, record.Schema())
+	if err == nil {
+		_, err = s.resolveSchema(subject, record)
+	}
+	if err != nil {
+		return fmt.Errorf("schema %T under subject %s: %w", record, subject, err)
+	}
+	return nil
+}
+
+func (s Serializer) prepareSchema(subject string, schema string) error {
+	err := s.checkCompatibility(subject, schema)
+	if err != nil {
+		return fmt.Errorf("subject %s: %w", subject, err)
+	}
+	switch {
+	case s.autoRegisterSchemas:
+		_, err = s.schemaRegistry.CreateSchema(subject, schema, schemaregistry.Avro)
+	case s.useLatestVersion:
+		var latest *schemaregistry.Schema
+		latest, err = s.schemaRegistry.GetLatestSchema(subject)
+		if err != nil {
+			err = notRegisteredError(subject, err)
+		} else if _, resolveErr := avro.Resolve(schema, latest.Schema()); resolveErr != nil {
+			err = fmt.Errorf("cannot write with latest schema %d: %v", latest.ID(), resolveErr)
+		}
+	default:
+		_, err = s.schemaRegistry.LookupSchema(subject, schema, schemaregistry.Avro)
+		err = notRegisteredError(subject, err)
+	}
+	if err != nil {
+		return fmt.Errorf("subject %s: %w", subject, err)
+	}
+	return nil
+}
+
+// checkCompatibility tests schemas about to be auto-registered,
+// so that incompatible ones are reported as such. Subjects that
+// do not exist yet accept any schema.
+func (s Serializer) checkCompatibility(subject string, schema string) error {
+	if !s.autoRegisterSchemas {
+		return nil
+	}
+	compatible, err := s.schemaRegistry.TestCompatibility(subject, schema, schemaregistry.Avro)
+	var registryErr *schemaregistry.Error
+	if errors.As(err, &registryErr) && registryErr.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !compatible {
+		return errors.New("schema is incompatible with the versions registered under the subject")
+	}
+	return nil
+}
+
+// prepareStartupSchemas prepares the schemas given to the bus
+// options, returning the errors of every schema.
+func (m *MessageBus) prepareStartupSchemas() []error {
+	if len(m.startupSchemas) == 0 {
+		return nil
+	}
+	var errs []error
+	for _, schemas := range m.startupSchemas {
+		serializer, ok := schemaRegistrySerializerOf(m.serializerFor(schemas.topic))
+		if !ok {
+			errs = append(errs, fmt.Errorf("topic %s: startup schemas only apply to Schema Registry serializers", schemas.topic))
+			continue
+		}
+		var err error
+		if schemas.fsys != nil {
+			err = serializer.PrepareSchemaFiles(schemas.topic, schemas.fsys, schemas.dir)
+		} else {
+			err = serializer.PrepareSchemas(schemas.topic, schemas.records...)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("topic %s: %w", schemas.topic, err))
+		}
+	}
+	return errs
+}
+
+// schemaRegistrySerializerOf returns the Serializer a topic serializer
+// is, or wraps, such as with WithEncryption or WithClaimCheck.
+func schemaRegistrySerializerOf(serializer ISerializer) (*Serializer, bool) {
+	for {
+		switch s := serializer.(type) {
+		case *Serializer:
+			return s, true
+		case wrappingSerializer:
+			serializer = s.unwrap()
+		default:
+			return nil, false
+		}
+	}
+}
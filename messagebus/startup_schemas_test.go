@@ -0,0 +1,38 @@
+package messagebus
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/kata-ai/messagebus-golang-kafka/messagebus/schemaregistry"
+)
+
+func TestPrepareSchemaFilesCompatibility(t *testing.T) {
+	registry := schemaregistry.NewMockClient()
+	serializer, err := NewSerializerWithClient(registry, TOPIC_NAME_STRATEGY)
+	if err != nil {
+		t.Fatalf("cannot create serializer: %v", err)
+	}
+	tests := []struct {
+		name   string
+		schema string
+		valid  bool
+	}{
+		// The registry knows no version of the subject yet
+		{"new subject", `{"type": "record", "name": "User", "fields": [{"name": "name", "type": "string"}]}`, true},
+		{"field with default", `{"type": "record", "name": "User", "fields": [{"name": "name", "type": "string"}, {"name": "age", "type": "int", "default": 0}]}`, true},
+		{"field without default", `{"type": "record", "name": "User", "fields": [{"name": "name", "type": "string"}, {"name": "email", "type": "string"}]}`, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := serializer.PrepareSchemaFiles("users", fstest.MapFS{"schemas/user.avsc": {Data: []byte(test.schema)}}, "schemas")
+			if test.valid != (err == nil) {
+				t.Errorf("error is %v, want an error: %v", err, !test.valid)
+			}
+		})
+	}
+	versions, err := registry.GetSchemaVersions("users-value")
+	if err != nil || len(versions) != 2 {
+		t.Errorf("registered versions %v, %v, want 2", versions, err)
+	}
+}
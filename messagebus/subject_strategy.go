@@ -1,6 +1,7 @@
 package messagebus
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 )
@@ -17,8 +18,10 @@ func (s SubjectStrategy) String() string {
 	return [...]string{"TOPIC_NAME_STRATEGY", "TOPIC_RECORD_NAME_STRATEGY", "RECORD_NAME_STRATEGY"}[s]
 }
 
-func prepareSubjectName(topic string, schemaStr string, strategy SubjectStrategy, isKey bool) (string, error) {
-	schema := Schema{schema: schemaStr}
+// prepareSubjectName returns the subject of a schema, given the name
+// of its record, which is the full name of Avro records and Protobuf
+// messages, or the title of JSON Schemas
+func prepareSubjectName(topic string, recordName string, strategy SubjectStrategy, isKey bool) (string, error) {
 	switch strategy {
 	case TOPIC_NAME_STRATEGY:
 		if isKey {
@@ -26,10 +29,28 @@ func prepareSubjectName(topic string, schemaStr string, strategy SubjectStrategy
 		}
 		return fmt.Sprintf("%s-value", topic), nil
 	case TOPIC_RECORD_NAME_STRATEGY:
-		return fmt.Sprintf("%s-%s", topic, schema.FullName()), nil
+		return fmt.Sprintf("%s-%s", topic, recordName), nil
 	case RECORD_NAME_STRATEGY:
-		return schema.FullName(), nil
+		return recordName, nil
 	default:
 		return "", errors.New("unknown subject strategy")
 	}
 }
+
+// recordFullName returns the namespaced name of an Avro record
+// schema, or the title of a JSON Schema
+func recordFullName(schema string) string {
+	var dat map[string]interface{}
+	_ = json.Unmarshal([]byte(schema), &dat)
+	name, _ := dat["name"].(string)
+	if name == "" {
+		name, _ = dat["title"].(string)
+	}
+	namespace, _ := dat["namespace"].(string)
+	switch namespace {
+	case "":
+		return name
+	default:
+		return fmt.Sprintf("%s.%s", namespace, name)
+	}
+}
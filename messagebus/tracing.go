@@ -0,0 +1,208 @@
+package messagebus
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/kata-ai/messagebus-golang-kafka/messagebus"
+
+// tracePropagator reads and writes W3C trace context, as the
+// traceparent and tracestate headers or message key fields.
+var tracePropagator = propagation.TraceContext{}
+
+// headerCarrier carries trace context in Kafka headers.
+type headerCarrier struct {
+	headers *[]kafka.Header
+}
+
+func (c headerCarrier) Get(key string) string {
+	for _, header := range *c.headers {
+		if header.Key == key {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
+
+func (c headerCarrier) Set(key string, value string) {
+	for i, header := range *c.headers {
+		if header.Key == key {
+			(*c.headers)[i].Value = []byte(value)
+			return
+		}
+	}
+	*c.headers = append(*c.headers, kafka.Header{Key: key, Value: []byte(value)})
+}
+
+func (c headerCarrier) Keys() []string {
+	keys := make([]string, 0, len(*c.headers))
+	for _, header := range *c.headers {
+		keys = append(keys, header.Key)
+	}
+	return keys
+}
+
+// keyCarrier carries trace context in the TraceParent and TraceState
+// fields of a message key.
+type keyCarrier struct {
+	key *MessageKey
+}
+
+func (c keyCarrier) Get(key string) string {
+	if c.key == nil {
+		return ""
+	}
+	switch key {
+	case "traceparent":
+		return c.key.TraceParent
+	case "tracestate":
+		return c.key.TraceState
+	}
+	return ""
+}
+
+func (c keyCarrier) Set(key string, value string) {
+	if c.key == nil {
+		return
+	}
+	switch key {
+	case "traceparent":
+		c.key.TraceParent = value
+	case "tracestate":
+		c.key.TraceState = value
+	}
+}
+
+func (c keyCarrier) Keys() []string {
+	return []string{"traceparent", "tracestate"}
+}
+
+// Trace sends, handled messages, requests and replies with spans of
+// a tracer of the tracer provider. W3C trace context travels in the
+// traceparent and tracestate headers of the messages sent.
+// Example:
+// 		WithTracerProvider(otel.GetTracerProvider())
+func WithTracerProvider(provider trace.TracerProvider) MessageBusOption {
+	return func(m *MessageBus) {
+		m.tracer = provider.Tracer(tracerName)
+	}
+}
+
+// Set the trace context of the message to the span of ctx, so that
+// sending it is traced as part of that span
+// Example:
+// 		NewMessageKey("billing-service", WithTraceContextFrom(r.Context()))
+func WithTraceContextFrom(ctx context.Context) MessageKeyOption {
+	return func(k *MessageKey) {
+		tracePropagator.Inject(ctx, keyCarrier{key: k})
+	}
+}
+
+// startSpan starts a span when the bus is traced, returning a span
+// that records nothing otherwise.
+func (m MessageBus) startSpan(parent context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	if m.tracer == nil {
+		return parent, trace.SpanFromContext(parent)
+	}
+	return m.tracer.Start(parent, name, opts...)
+}
+
+// startSendSpan starts the producer span of a message, child of the
+// span its message key carries the trace context of.
+func (m MessageBus) startSendSpan(topic string, message *ProducerRecord) (context.Context, trace.Span) {
+	parent := tracePropagator.Extract(context.Background(), keyCarrier{key: message.Key})
+	return m.startSpan(parent, "send "+topic,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(messageAttributes(topic, message.Key)...),
+	)
+}
+
+// startProcessSpan starts the consumer span handling a message, child
+// of the span that sent it, as told by its headers or else by its
+// message key.
+func (m *MessageBus) startProcessSpan(message *kafka.Message, record *ConsumerRecord) (context.Context, trace.Span) {
+	parent := tracePropagator.Extract(context.Background(), headerCarrier{headers: &message.Headers})
+	var key *MessageKey
+	if record != nil {
+		key = record.Key
+	}
+	if !trace.SpanContextFromContext(parent).IsValid() {
+		parent = tracePropagator.Extract(context.Background(), keyCarrier{key: key})
+	}
+	attributes := append(messageAttributes(*message.TopicPartition.Topic, key),
+		attribute.String("messaging.destination.partition.id", strconv.Itoa(int(message.TopicPartition.Partition))),
+		attribute.Int64("messaging.kafka.offset", int64(message.TopicPartition.Offset)),
+		attribute.String("messaging.consumer.group.name", m.consumerGroup()),
+	)
+	return m.startSpan(parent, "process "+*message.TopicPartition.Topic,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(attributes...),
+	)
+}
+
+// startRequestSpan starts the client span of a request, waiting for
+// its reply.
+func (m MessageBus) startRequestSpan(topic string, message *ProducerRecord) (context.Context, trace.Span) {
+	parent := tracePropagator.Extract(context.Background(), keyCarrier{key: message.Key})
+	return m.startSpan(parent, "request "+topic,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(messageAttributes(topic, message.Key)...),
+	)
+}
+
+// replySpanContext returns the context of the span a reply was sent
+// in, which its message key carries.
+func replySpanContext(reply *ConsumerRecord) trace.SpanContext {
+	return trace.SpanContextFromContext(tracePropagator.Extract(context.Background(), keyCarrier{key: reply.Key}))
+}
+
+// startReplySpan starts the span of a reply, child of the span
+// handling the request and linked to the span that sent it.
+func (m MessageContext) startReplySpan(replyTopic string) (context.Context, trace.Span) {
+	parent := m.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	bus, ok := m.Sender.(*MessageBus)
+	if !ok {
+		return parent, trace.SpanFromContext(parent)
+	}
+	request := trace.SpanContextFromContext(tracePropagator.Extract(context.Background(), keyCarrier{key: m.Incoming.Key}))
+	return bus.startSpan(parent, "reply "+replyTopic,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(messageAttributes(replyTopic, m.Incoming.Key)...),
+		trace.WithLinks(trace.Link{SpanContext: request}),
+	)
+}
+
+// messageAttributes returns the attributes of the spans of a message.
+func messageAttributes(topic string, key *MessageKey) []attribute.KeyValue {
+	attributes := []attribute.KeyValue{
+		attribute.String("messaging.system", "kafka"),
+		attribute.String("messaging.destination.name", topic),
+	}
+	if key == nil {
+		return attributes
+	}
+	attributes = append(attributes, attribute.String("messaging.message.id", key.MessageId))
+	if key.CorrelationId != "" {
+		attributes = append(attributes, attribute.String("messaging.message.conversation_id", key.CorrelationId))
+	}
+	return attributes
+}
+
+// endSpan ends a span, recording the error it failed with, if any.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
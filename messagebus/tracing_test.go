@@ -0,0 +1,144 @@
+package messagebus
+
+import (
+	"context"
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// sentMessages holds the messages a traced bus sends.
+type sentMessages struct {
+	messages []*kafka.Message
+}
+
+func (s *sentMessages) deliver(message *kafka.Message) (kafka.TopicPartition, error) {
+	s.messages = append(s.messages, message)
+	return kafka.TopicPartition{Topic: message.TopicPartition.Topic, Offset: kafka.Offset(len(s.messages))}, nil
+}
+
+func newTracedBus(sent *sentMessages) (*MessageBus, *tracetest.SpanRecorder) {
+	recorder := tracetest.NewSpanRecorder()
+	m := &MessageBus{
+		Serializer:       NewJSONSerializer(),
+		topicSerializers: make(map[string]ISerializer),
+		deliver:          sent.deliver,
+		producerConfig:   &ProducerConfiguration{},
+		consumerConfig:   NewConsumerConfig("group-1"),
+	}
+	WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))(m)
+	return m, recorder
+}
+
+// consume returns a sent message as consumed from its topic.
+func consume(t *testing.T, m *MessageBus, message *kafka.Message) (*kafka.Message, *ConsumerRecord) {
+	t.Helper()
+	consumed := &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: message.TopicPartition.Topic, Offset: 1},
+		Key:            message.Key,
+		Value:          message.Value,
+		Headers:        message.Headers,
+	}
+	record, err := m.serializerFor(*consumed.TopicPartition.Topic).Deserialize(consumed)
+	if err != nil {
+		t.Fatalf("cannot deserialize record: %v", err)
+	}
+	return consumed, record
+}
+
+func endedSpan(t *testing.T, recorder *tracetest.SpanRecorder, name string) sdktrace.ReadOnlySpan {
+	t.Helper()
+	for _, span := range recorder.Ended() {
+		if span.Name() == name {
+			return span
+		}
+	}
+	t.Fatalf("no span %q ended", name)
+	return nil
+}
+
+func TestTraceSendAndProcess(t *testing.T) {
+	sent := &sentMessages{}
+	m, recorder := newTracedBus(sent)
+	key, err := NewMessageKey("order-service")
+	if err != nil {
+		t.Fatalf("cannot create message key: %v", err)
+	}
+	if _, err := m.Send("orders", NewProducerRecord(key, &keyedOrder{Id: "order-1"})); err != nil {
+		t.Fatalf("cannot send record: %v", err)
+	}
+	message, record := consume(t, m, sent.messages[0])
+	_, span := m.startProcessSpan(message, record)
+	span.End()
+
+	send := endedSpan(t, recorder, "send orders")
+	process := endedSpan(t, recorder, "process orders")
+	if process.Parent().SpanID() != send.SpanContext().SpanID() || process.SpanContext().TraceID() != send.SpanContext().TraceID() {
+		t.Errorf("process span has parent %v, want the send span %v", process.Parent(), send.SpanContext())
+	}
+	if process.SpanKind() != trace.SpanKindConsumer || send.SpanKind() != trace.SpanKindProducer {
+		t.Errorf("span kinds are %v and %v", send.SpanKind(), process.SpanKind())
+	}
+}
+
+func TestTraceProcessFromKey(t *testing.T) {
+	m, recorder := newTracedBus(&sentMessages{})
+	ctx, parent := m.startSpan(context.Background(), "checkout")
+	key, err := NewMessageKey("order-service", WithTraceContextFrom(ctx))
+	if err != nil {
+		t.Fatalf("cannot create message key: %v", err)
+	}
+	parent.End()
+	// A message without trace headers, as sent by older versions
+	topic := "orders"
+	message := &kafka.Message{TopicPartition: kafka.TopicPartition{Topic: &topic}}
+	_, span := m.startProcessSpan(message, &ConsumerRecord{Key: key})
+	span.End()
+
+	process := endedSpan(t, recorder, "process orders")
+	if process.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("process span has parent %v, want the span of the key %v", process.Parent(), parent.SpanContext())
+	}
+}
+
+func TestTraceRequestReply(t *testing.T) {
+	sent := &sentMessages{}
+	m, recorder := newTracedBus(sent)
+	key, err := NewMessageKey("order-service")
+	if err != nil {
+		t.Fatalf("cannot create message key: %v", err)
+	}
+	key.ReplyTopic = "order-replies"
+	key.CorrelationId = "request-1"
+	request := NewProducerRecord(key, &keyedOrder{Id: "order-1"})
+	ctx, requestSpan := m.startRequestSpan("orders", request)
+	tracePropagator.Inject(ctx, keyCarrier{key: request.Key})
+	if _, err := m.Send("orders", request); err != nil {
+		t.Fatalf("cannot send request: %v", err)
+	}
+
+	message, record := consume(t, m, sent.messages[0])
+	processCtx, processSpan := m.startProcessSpan(message, record)
+	replyContext := MessageContext{Incoming: record, Sender: m, Context: processCtx}
+	if _, err := replyContext.Reply(NewProducerRecord(nil, &keyedOrder{Id: "order-1"})); err != nil {
+		t.Fatalf("cannot reply: %v", err)
+	}
+	processSpan.End()
+	_, reply := consume(t, m, sent.messages[1])
+	requestSpan.End()
+
+	replySpan := endedSpan(t, recorder, "reply order-replies")
+	if replySpan.Parent().SpanID() != processSpan.SpanContext().SpanID() {
+		t.Errorf("reply span has parent %v, want the process span %v", replySpan.Parent(), processSpan.SpanContext())
+	}
+	links := replySpan.Links()
+	if len(links) != 1 || links[0].SpanContext.SpanID() != requestSpan.SpanContext().SpanID() {
+		t.Errorf("reply span links %v, want the request span %v", links, requestSpan.SpanContext())
+	}
+	if got := replySpanContext(reply); got.SpanID() != replySpan.SpanContext().SpanID() {
+		t.Errorf("reply carries span %v, want the reply span %v", got, replySpan.SpanContext())
+	}
+}
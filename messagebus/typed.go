@@ -0,0 +1,145 @@
+package messagebus
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+
+	"github.com/actgardner/gogen-avro/v7/container"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"google.golang.org/protobuf/proto"
+)
+
+// TypedContext is the message context handed to typed handlers,
+// with the incoming value already decoded into its type
+type TypedContext[T any] struct {
+	MessageContext
+	Value T
+}
+
+// failingHandler is implemented by handlers returning the error they
+// failed with, which the bus dead-letters instead of committing.
+type failingHandler interface {
+	handleMessage(context MessageContext) error
+}
+
+var errNoIncomingRecord = errors.New("message has no incoming record")
+
+type typedHandler[T any] struct {
+	handle func(ctx TypedContext[T]) error
+}
+
+func (h typedHandler[T]) HandleMessage(context MessageContext) {
+	err := h.handleMessage(context)
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+	}
+}
+
+func (h typedHandler[T]) handleMessage(context MessageContext) error {
+	if context.Incoming == nil {
+		return errNoIncomingRecord
+	}
+	value, ok := context.Incoming.Record.(T)
+	if !ok {
+		return fmt.Errorf("message at topic %s offset %s is not a %T", context.Incoming.Topic, context.Incoming.Offset, value)
+	}
+	return h.handle(TypedContext[T]{MessageContext: context, Value: value})
+}
+
+// RegisterTypedHandler adds a handler for a topic whose Avro values
+// are decoded into T, which must be a pointer to a gogen-avro record.
+// Messages the handler returns an error for, or whose value is not
+// a T, are sent to the dead-letter topic of the consumer configuration
+// when there is one, and are reported to stderr either way.
+// Example:
+// 		RegisterTypedHandler(bus, "topic-1", func(ctx TypedContext[*schemas.JohnySchema]) error {
+// 			fmt.Println(ctx.Value.Name)
+// 			return nil
+// 		})
+func RegisterTypedHandler[T container.AvroRecord](bus *MessageBus, topic string, handle func(ctx TypedContext[T]) error) {
+	// Fail at registration rather than at the first message
+	_ = newRecord[T]()
+	bus.serializerFor(topic).RegisterValueType(topic, func() container.AvroRecord {
+		return newRecord[T]()
+	})
+	bus.RegisterHandler(topic, typedHandler[T]{handle: handle})
+}
+
+// RegisterProtobufHandler adds a handler for a topic whose Protobuf
+// values are decoded into T, which must be a generated Go message.
+// Failed messages are handled as with RegisterTypedHandler.
+// Example:
+// 		RegisterProtobufHandler(bus, "orders", func(ctx TypedContext[*pb.Order]) error {
+// 			fmt.Println(ctx.Value.GetId())
+// 			return nil
+// 		})
+func RegisterProtobufHandler[T proto.Message](bus *MessageBus, topic string, handle func(ctx TypedContext[T]) error) {
+	_ = newRecord[T]()
+	bus.serializerFor(topic).RegisterProtobufValueType(topic, func() proto.Message {
+		return newRecord[T]()
+	})
+	bus.RegisterHandler(topic, typedHandler[T]{handle: handle})
+}
+
+// RegisterJSONHandler adds a handler for a topic whose JSON Schema
+// or plain JSON values are decoded into T, which must be a pointer to
+// any Go type. Failed messages are handled as with RegisterTypedHandler.
+// Example:
+// 		RegisterJSONHandler(bus, "orders", func(ctx TypedContext[*Order]) error {
+// 			fmt.Println(ctx.Value.ID)
+// 			return nil
+// 		})
+func RegisterJSONHandler[T any](bus *MessageBus, topic string, handle func(ctx TypedContext[T]) error) {
+	_ = newRecord[T]()
+	bus.serializerFor(topic).RegisterJSONValueType(topic, func() interface{} {
+		return newRecord[T]()
+	})
+	bus.RegisterHandler(topic, typedHandler[T]{handle: handle})
+}
+
+// SendTyped sends a gogen-avro record of type T to a topic with Avro
+// Returns kafka offset object and error
+func SendTyped[T container.AvroRecord](bus IMessageBus, topic string, key *MessageKey, value T) (kafka.Offset, error) {
+	return bus.Send(topic, NewProducerRecord(key, value))
+}
+
+// SendProtobuf sends a Go message of type T to a topic with Protobuf
+// Returns kafka offset object and error
+func SendProtobuf[T proto.Message](bus IMessageBus, topic string, key *MessageKey, value T) (kafka.Offset, error) {
+	return bus.Send(topic, NewProducerRecord(key, value))
+}
+
+// SendJSON sends a value of type T to a topic with JSON Schema, or
+// as plain JSON with a JSONSerializer
+// Returns kafka offset object and error
+func SendJSON[T any](bus IMessageBus, topic string, key *MessageKey, value T) (kafka.Offset, error) {
+	return bus.Send(topic, NewProducerRecord(key, value))
+}
+
+// handleFailed reports a message that could not be decoded or that
+// its handler failed on, consumed as the given chunks, sending them to
+// the dead-letter topic first when one is configured, which retries
+// until the bus disconnects and leaves the message uncommitted then.
+func (m *MessageBus) handleFailed(message *kafka.Message, chunks []*kafka.Message, reason error) {
+	_, _ = fmt.Fprintf(os.Stderr, "cannot handle message at offset %v of %s: %v\n",
+		message.TopicPartition.Offset, *message.TopicPartition.Topic, reason)
+	if m.consumerConfig.DeadLetterTopic != "" && m.deliver != nil {
+		err := m.deadLetter(chunks, reason.Error())
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+			return
+		}
+	}
+	m.commit(message)
+}
+
+func newRecord[T any]() T {
+	var zero T
+	t := reflect.TypeOf(zero)
+	if t == nil || t.Kind() != reflect.Ptr {
+		panic(fmt.Sprintf("typed value %v must be a pointer", t))
+	}
+	return reflect.New(t.Elem()).Interface().(T)
+}
@@ -0,0 +1,40 @@
+package messagebus
+
+import (
+	"errors"
+	"testing"
+)
+
+type typedOrder struct {
+	Id string `json:"id"`
+}
+
+func TestTypedHandlerErrors(t *testing.T) {
+	failure := errors.New("order rejected")
+	var handler failingHandler = typedHandler[*typedOrder]{handle: func(ctx TypedContext[*typedOrder]) error {
+		if ctx.Value.Id == "" {
+			return failure
+		}
+		return nil
+	}}
+	tests := []struct {
+		name   string
+		record interface{}
+		err    bool
+	}{
+		{"handled", &typedOrder{Id: "order-1"}, false},
+		{"handler error", &typedOrder{}, true},
+		{"another type", map[string]interface{}{"id": "order-1"}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := handler.handleMessage(MessageContext{Incoming: &ConsumerRecord{Topic: "orders", Record: test.record}})
+			if test.err != (err != nil) {
+				t.Errorf("error is %v", err)
+			}
+		})
+	}
+	if err := handler.handleMessage(MessageContext{}); err == nil {
+		t.Error("handled a message without incoming record")
+	}
+}
@@ -0,0 +1,67 @@
+package messagebus
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+
+	"github.com/actgardner/gogen-avro/v7/compiler"
+	"github.com/actgardner/gogen-avro/v7/container"
+	"github.com/actgardner/gogen-avro/v7/vm"
+)
+
+// valueTypeRegistry keeps the types values are decoded into, which
+// are gogen-avro records, Go messages or Go types for JSON values,
+// along with the compiled programs resolving each writer schema
+// into the gogen-avro records.
+type valueTypeRegistry struct {
+	factories     map[string]func() interface{}
+	factoriesLock sync.RWMutex
+	programs      map[string]*vm.Program
+	programsLock  sync.RWMutex
+}
+
+func newValueTypeRegistry() *valueTypeRegistry {
+	return &valueTypeRegistry{
+		factories: make(map[string]func() interface{}),
+		programs:  make(map[string]*vm.Program),
+	}
+}
+
+func (r *valueTypeRegistry) register(name string, newValue func() interface{}) {
+	r.factoriesLock.Lock()
+	r.factories[name] = newValue
+	r.factoriesLock.Unlock()
+}
+
+// lookup returns the value factory registered for the topic,
+// falling back to the one registered for the value subject.
+func (r *valueTypeRegistry) lookup(topic string, valueSubject string) func() interface{} {
+	r.factoriesLock.RLock()
+	defer r.factoriesLock.RUnlock()
+	if newValue, ok := r.factories[topic]; ok {
+		return newValue
+	}
+	return r.factories[valueSubject]
+}
+
+// decode resolves the payload written with the writer schema
+// into the target record, compiling the writer to reader
+// program only once per schema pair.
+func (r *valueTypeRegistry) decode(writer *Schema, payload []byte, target container.AvroRecord) error {
+	key := fmt.Sprintf("%d-%s", writer.ID(), target.Schema())
+	r.programsLock.RLock()
+	program := r.programs[key]
+	r.programsLock.RUnlock()
+	if program == nil {
+		var err error
+		program, err = compiler.CompileSchemaBytes([]byte(writer.Schema()), []byte(target.Schema()))
+		if err != nil {
+			return fmt.Errorf("cannot resolve schema %d into %T: %v", writer.ID(), target, err)
+		}
+		r.programsLock.Lock()
+		r.programs[key] = program
+		r.programsLock.Unlock()
+	}
+	return vm.Eval(bytes.NewReader(payload), program, target)
+}
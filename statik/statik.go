@@ -7,6 +7,6 @@ import (
 )
 
 func init() {
-	data := "PK\x03\x04\x14\x00\x08\x00\x08\x00|F(Q\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x13\x00	\x00message_header.avscUT\x05\x00\x01,FW_\xa4\x91=N\xc40\x10\x85\xfb\x9cb\xe4z\xb5\x07\xd8\x92\n\x84\xa8\x16\xd1 \x8a\xc1\x99DC\x1c\xdb\x9a\x99D\x8aV{w\x14\x07\x16$~\no\xe3\xe2=\x7f\x9f\x9f\xe4S\x03\xe0l\xc9\xe4\x0e\xe0\x84|\x92\xd6\xed\xd6,\xe2H\x9a\xd1\x97\x02y?\xa0\xe1~\xc0n\xc0\xaf~\xad\x1eH\x15{\xba\xa7e\xcb;\xa6\xd0\xaa;\xc0s\x03\x00p*\xe7\xb7\xfb3\x86\x89\x8e\xd3\xeb\x1by+Di?\x17\xa8	\xc7\xde\x95\xf8\xbc\xfb\xdd0n/\xde\xb5u\xb8O\"\x14\xd08\xc5zE\x9cI\xf4*\x87P\x0e\xcbc\xca\xec\xff\xe4/yK\x1dN\xc1\xd6\xea\x7fi\x12\xee9\x1eIf\xf6T\xb7kS\xdc&\xb5\x92T9>~\xe8f\xd2'\x12\xe5\x14\xeb4\xc6#\xa9\xe1\x98\x7f\xe2!]\xe0\x06\xe0\xa597\xef\x01\x00\x00\xff\xffPK\x07\x08f\xa1r\x0f\xd6\x00\x00\x00\xc9\x02\x00\x00PK\x01\x02\x14\x03\x14\x00\x08\x00\x08\x00|F(Qf\xa1r\x0f\xd6\x00\x00\x00\xc9\x02\x00\x00\x13\x00	\x00\x00\x00\x00\x00\x00\x00\x00\x00\xa4\x81\x00\x00\x00\x00message_header.avscUT\x05\x00\x01,FW_PK\x05\x06\x00\x00\x00\x00\x01\x00\x01\x00J\x00\x00\x00 \x01\x00\x00\x00\x00"
+	data := "PK\x03\x04\x14\x00\x08\x00\x08\x00OMS]\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x13\x00	\x00message_header.avscUT\x05\x00\x01\x06\xe6\xd5j\xac\x94?O\xc3@\x0c\xc5\xf7|\n+sU1w\x83	\x84\x90\x90\x8aX\x10\x83{q+\xd3\xe4\xeed;\x15Q\x95\xef\x8er\x0d4\xa8\x7f\xa0\x81\xd5\xbe\xf7\xf3\x8b\xfd\x94m\x06\x90[\x13)\x9fA.\xe4\x82\x14\xf9\xa4\xaby\xacH#\xba\xd4@\x9e\xae\xd1p\xba\xc6\xe5\x1a\xf7\xfd\xae\xf5@\xaa\xb8\xa2{jv\xf5%SYh>\x83\x97\x0c\x00\xa0\xe3\x03\x0c\xdeo\xb0\xaci^/\xde\xc8YR\x00\x0c\x1c\xa8	\xfbU\x9eD\xed\xe48\xa1\xdaM\xbc+\xc6\xc9]\x10\xa1\x12\x8d\x83\x1f\x8f\xf0\x1b\x12\xfd\x13C(\x96\xcdS\x88\xecNz\xf8\xaa\x17\xb4\xc4\xba\xb4Nv\xdeX\x10^\xb1\x9f\x93l\xd8\xd1I\xee/\x10\xb7A-UF1\xfa\x0b\xdd\xd4\xfaL\xa2\x1c\xfc8\x8cqEjX\xc5Cy\x19~\xda\xaf\x91Gog\xaes\xf9vM\xd0\xd1#\ny\xfbo\xea\xdc\xd0N\x1f\xec\xf2 D\xe1 l\xcd!\x92\xbd\x1d\x0b\xd6\xd5\xd9P\xd0{d!\xbd>\xf2\xdd\xe9\x12\x17\x03\xd1LxQ\x1b\xe9\x01\xf1\xf3\xed`F\x85\xfb\x08@\xff\x0b\xd1\xc1\xd2{E\xef\xfe\x9b\x8dm\x9b\x9am\x06\xf0\x9a\xb5\xd9\xc7\x00PK\x07\x08c\xabe\x0d*\x01\x00\x00\xef\x04\x00\x00PK\x01\x02\x14\x03\x14\x00\x08\x00\x08\x00OMS]c\xabe\x0d*\x01\x00\x00\xef\x04\x00\x00\x13\x00	\x00\x00\x00\x00\x00\x00\x00\x00\x00\xb4\x81\x00\x00\x00\x00message_header.avscUT\x05\x00\x01\x06\xe6\xd5jPK\x05\x06\x00\x00\x00\x00\x01\x00\x01\x00J\x00\x00\x00t\x01\x00\x00\x00\x00"
 	fs.Register(data)
 }